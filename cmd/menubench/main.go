@@ -0,0 +1,123 @@
+// Command menubench compares the per-group queries ShowMenu used to run with the
+// single aggregation it runs now, reporting database round trips and latency:
+//
+//	go run ./cmd/menubench -menu <menu id> -n 50
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var queries int64
+
+func loadPerGroup(ctx context.Context, db *mongo.Database, menuID primitive.ObjectID) (models.Menu, error) {
+	var menu models.Menu
+	if err := db.Collection("menu").FindOne(ctx, bson.M{"_id": menuID}).Decode(&menu); err != nil {
+		return menu, err
+	}
+
+	cursor, err := db.Collection("menu-group").Find(ctx, bson.M{"menuid": menuID.Hex()})
+	if err != nil {
+		return menu, err
+	}
+	if err := cursor.All(ctx, &menu.MenuGroup); err != nil {
+		return menu, err
+	}
+
+	for i, group := range menu.MenuGroup {
+		cursor, err := db.Collection("menu-item").Find(ctx, bson.M{"groupid": group.ID.Hex()})
+		if err != nil {
+			return menu, err
+		}
+		if err := cursor.All(ctx, &menu.MenuGroup[i].MenuItem); err != nil {
+			return menu, err
+		}
+	}
+	return menu, nil
+}
+
+func loadAggregated(ctx context.Context, db *mongo.Database, menuID primitive.ObjectID) (models.Menu, error) {
+	var menus []models.Menu
+	cursor, err := db.Collection("menu").Aggregate(ctx, helper.MenuTreePipeline(menuID))
+	if err != nil {
+		return models.Menu{}, err
+	}
+	if err := cursor.All(ctx, &menus); err != nil {
+		return models.Menu{}, err
+	}
+	if len(menus) == 0 {
+		return models.Menu{}, mongo.ErrNoDocuments
+	}
+	return menus[0], nil
+}
+
+func run(name string, n int, load func() (models.Menu, error)) {
+	atomic.StoreInt64(&queries, 0)
+	start := time.Now()
+
+	var menu models.Menu
+	var err error
+	for i := 0; i < n; i++ {
+		if menu, err = load(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("%-10s groups=%d queries/op=%d latency/op=%s\n",
+		name, len(menu.MenuGroup), atomic.LoadInt64(&queries)/int64(n), elapsed/time.Duration(n))
+}
+
+func main() {
+	menuHex := flag.String("menu", "", "id of the menu to load")
+	n := flag.Int("n", 20, "number of iterations")
+	flag.Parse()
+
+	menuID, err := primitive.ObjectIDFromHex(*menuHex)
+	if err != nil || *n < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(".env"); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	monitor := &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			switch e.CommandName {
+			case "find", "aggregate", "getMore":
+				atomic.AddInt64(&queries, 1)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGODB_URL")).SetMonitor(monitor))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+	db := client.Database(database.DatabaseName())
+
+	run("per-group", *n, func() (models.Menu, error) { return loadPerGroup(ctx, db, menuID) })
+	run("aggregate", *n, func() (models.Menu, error) { return loadAggregated(ctx, db, menuID) })
+}
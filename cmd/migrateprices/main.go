@@ -0,0 +1,109 @@
+// Command migrateprices converts float64 item prices and option price deltas to
+// Decimal128, rounded to the decimals of the menu's currency, and gives menus
+// without a currency the default one. It is safe to run more than once:
+//
+//	go run ./cmd/migrateprices
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	menuCollection := database.OpenCollection(database.Client, "menu")
+	menuGroupCollection := database.OpenCollection(database.Client, "menu-group")
+	menuItemCollection := database.OpenCollection(database.Client, "menu-item")
+
+	result, err := menuCollection.UpdateMany(ctx,
+		bson.M{"$or": []bson.M{{"currency": bson.M{"$exists": false}}, {"currency": ""}}},
+		bson.M{"$set": bson.M{"currency": helper.DefaultCurrency}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("menus given the default currency: %d\n", result.ModifiedCount)
+
+	var menus []models.Menu
+	cursor, err := menuCollection.Find(ctx, bson.M{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cursor.All(ctx, &menus); err != nil {
+		log.Fatal(err)
+	}
+	digitsByMenu := make(map[string]int, len(menus))
+	for _, menu := range menus {
+		digitsByMenu[menu.ID.Hex()] = helper.CurrencyDigits(helper.MenuCurrency(menu))
+	}
+
+	var groups []models.MenuGroup
+	cursor, err = menuGroupCollection.Find(ctx, bson.M{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		log.Fatal(err)
+	}
+	digitsByGroup := make(map[string]int, len(groups))
+	for _, group := range groups {
+		digits := helper.CurrencyDigits(helper.DefaultCurrency)
+		if group.MenuID != nil {
+			if menuDigits, ok := digitsByMenu[*group.MenuID]; ok {
+				digits = menuDigits
+			}
+		}
+		digitsByGroup[group.ID.Hex()] = digits
+	}
+
+	cursor, err = menuItemCollection.Find(ctx, bson.M{"$or": []bson.M{
+		{"price": bson.M{"$type": "double"}},
+		{"optiongroup.options.pricedelta": bson.M{"$type": "double"}},
+	}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var item models.MenuItem
+		if err := cursor.Decode(&item); err != nil {
+			log.Fatal(err)
+		}
+
+		digits := helper.CurrencyDigits(helper.DefaultCurrency)
+		if item.GroupID != nil {
+			if groupDigits, ok := digitsByGroup[*item.GroupID]; ok {
+				digits = groupDigits
+			}
+		}
+
+		item.Price = item.Price.Round(digits)
+		for i := range item.OptionGroup {
+			for j := range item.OptionGroup[i].Options {
+				option := &item.OptionGroup[i].Options[j]
+				option.PriceDelta = option.PriceDelta.Round(digits)
+			}
+		}
+
+		update := bson.M{"$set": bson.M{"price": item.Price, "optiongroup": item.OptionGroup}}
+		if _, err := menuItemCollection.UpdateOne(ctx, bson.M{"_id": item.ID}, update); err != nil {
+			log.Fatal(err)
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("items migrated: %d\n", migrated)
+}
@@ -0,0 +1,48 @@
+// Command orphans reports menu groups and items whose parent menu or group no
+// longer exists. Run it from the repository root so the .env file is found:
+//
+//	go run ./cmd/orphans          # report only
+//	go run ./cmd/orphans -purge   # report and delete
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	helper "github.com/sencerarslan/go-app/helpers"
+)
+
+func main() {
+	purge := flag.Bool("purge", false, "delete the orphans that were found")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	orphans, err := helper.FindOrphans(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("orphaned groups: %d\n", len(orphans.GroupIDs))
+	for _, id := range orphans.GroupIDs {
+		fmt.Println("  menu-group", id.Hex())
+	}
+	fmt.Printf("orphaned items: %d\n", len(orphans.ItemIDs))
+	for _, id := range orphans.ItemIDs {
+		fmt.Println("  menu-item", id.Hex())
+	}
+
+	if !*purge {
+		return
+	}
+
+	groups, items, err := helper.PurgeOrphans(ctx, orphans)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("deleted %d groups and %d items\n", groups, items)
+}
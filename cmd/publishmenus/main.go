@@ -0,0 +1,50 @@
+// Command publishmenus publishes the current contents of every menu that has never
+// been published, so that menus created before publishing existed stay visible to
+// guests. It is safe to run more than once:
+//
+//	go run ./cmd/publishmenus
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	menuCollection := database.OpenCollection(database.Client, "menu")
+
+	var menus []models.Menu
+	filter := bson.M{"deleted_at": nil, "$or": []bson.M{{"publishedversion": bson.M{"$exists": false}}, {"publishedversion": 0}}}
+	cursor, err := menuCollection.Find(ctx, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cursor.All(ctx, &menus); err != nil {
+		log.Fatal(err)
+	}
+
+	note := "Initial version"
+	published := 0
+	for _, menu := range menus {
+		userID := ""
+		if menu.UserID != nil {
+			userID = *menu.UserID
+		}
+		if _, err := helper.PublishMenu(ctx, menu.ID, userID, &note); err != nil {
+			log.Printf("menu %s: %v", menu.ID.Hex(), err)
+			continue
+		}
+		published++
+	}
+	fmt.Printf("menus published: %d of %d\n", published, len(menus))
+}
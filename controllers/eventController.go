@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// eventHeartbeat keeps idle connections open through proxies that close silent
+// connections.
+const eventHeartbeat = 25 * time.Second
+
+func lastEventID(c *gin.Context) uint64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return id
+}
+
+func writeEvent(c *gin.Context, event helper.MenuEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// streamEvents sends the events that pass filter as server-sent events until the
+// client disconnects. A client that resumes after the kept history receives a
+// "resync" event and should reload what it shows. A stream opened with a token
+// ends with an "expired" event once expiresAt has passed, and without one once
+// revoked reports true; revoked is asked with every heartbeat.
+func streamEvents(c *gin.Context, filter func(helper.MenuEvent) bool, public bool, expiresAt time.Time, revoked func() bool) {
+	events, missed, resumed, cancel := helper.SubscribeMenuEvents(filter, lastEventID(c))
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	send := func(event helper.MenuEvent) bool {
+		if public {
+			event.Data = nil
+		}
+		return writeEvent(c, event) == nil
+	}
+
+	if !resumed {
+		fmt.Fprint(c.Writer, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if !send(event) {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	var expired <-chan time.Time
+	if !expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			fmt.Fprint(c.Writer, "event: expired\ndata: {}\n\n")
+			c.Writer.Flush()
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !send(event) {
+				return
+			}
+		case <-heartbeat.C:
+			if revoked != nil && revoked() {
+				return
+			}
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// MenuEventsToken issues the stream token that MenuEvents takes in place of the
+// access token.
+func MenuEventsToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, expiresAt, err := helper.GenerateStreamToken(c.GetString("uid"), c.GetString("user_type"), c.GetString("family"), c.GetString("jti"), c.GetInt64("expires_at"))
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while creating the stream token")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(gin.H{"stream_token": token, "expires_at": time.Unix(expiresAt, 0)}, "Stream token created")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// MenuEvents streams the changes to every menu the user of the stream token owns,
+// until the token expires or is revoked.
+func MenuEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("uid")
+		claims, _ := c.MustGet("stream_claims").(*helper.SignedDetails)
+		streamEvents(c, func(event helper.MenuEvent) bool {
+			return event.OwnerID == userID
+		}, false, time.Unix(c.GetInt64("expires_at"), 0), func() bool {
+			revoked, err := helper.IsTokenRevoked(claims)
+			return revoked || err != nil
+		})
+	}
+}
+
+// PublicMenuEvents streams the changes to a single menu for guests viewing it.
+// The events only name what changed; clients reload it through /menu/show.
+func PublicMenuEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		menuID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response := helper.NotFoundResponse(nil, "Menu not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := useContext()
+		var menu models.Menu
+		err = menuCollection.FindOne(ctx, bson.M{"_id": menuID, "deleted_at": nil}).Decode(&menu)
+		cancel()
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Menu not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		streamEvents(c, func(event helper.MenuEvent) bool {
+			return event.MenuID == menu.ID.Hex() && !event.Private
+		}, true, time.Time{}, nil)
+	}
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+)
+
+func TestStreamEventsEndsWhenTheTokenExpires(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/menu/events", nil)
+
+	done := make(chan struct{})
+	go func() {
+		streamEvents(c, func(helper.MenuEvent) bool { return false }, false, time.Now().Add(50*time.Millisecond), nil)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is still open after its token expired")
+	}
+	if !strings.HasSuffix(w.Body.String(), "event: expired\ndata: {}\n\n") {
+		t.Errorf("stream = %q, want it to end with an expired event", w.Body.String())
+	}
+}
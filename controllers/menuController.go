@@ -7,13 +7,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sencerarslan/go-app/database"
-	"github.com/sencerarslan/go-app/middleware"
 	"github.com/sencerarslan/go-app/models"
 
 	helper "github.com/sencerarslan/go-app/helpers"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
@@ -25,23 +25,50 @@ func useContext() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
-func getMenuByID(menuID primitive.ObjectID) (models.Menu, error) {
-	ctx, cancel := useContext()
-	defer cancel()
+// ownershipGranted sends the matching error response for a failed ownership check.
+func ownershipGranted(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case helper.ErrForbidden:
+		response := helper.ForbiddenResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusForbidden)
+	case helper.ErrNotFound:
+		response := helper.NotFoundResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusNotFound)
+	case helper.ErrParentDeleted:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusConflict)
+	default:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+	}
+	return false
+}
 
-	var data models.Menu
-	err := menuCollection.FindOne(ctx, bson.M{"_id": menuID}).Decode(&data)
+// itemPricesValid checks the item's amounts against the currency of the menu that
+// the given menu, group or item belongs to.
+func itemPricesValid(ctx context.Context, c *gin.Context, menuItem models.MenuItem, kind string, id primitive.ObjectID) bool {
+	menu, err := helper.MenuOf(ctx, kind, id)
 	if err != nil {
-		return models.Menu{}, err
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+		return false
 	}
 
-	return data, nil
+	if err := helper.ValidateItemPrices(menuItem, helper.MenuCurrency(menu)); err != nil {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+		return false
+	}
+	return true
 }
+
 func getMenuGroupByAll(menuID string) ([]models.MenuGroup, error) {
 	ctx, cancel := useContext()
 	defer cancel()
 
-	cursor, err := menuGroupCollection.Find(ctx, bson.M{"menuid": menuID})
+	cursor, err := menuGroupCollection.Find(ctx, bson.M{"menuid": menuID, "deleted_at": nil}, options.Find().SetSort(helper.PositionSort))
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +90,7 @@ func getMenuItemByID(groupID string) ([]models.MenuItem, error) {
 	ctx, cancel := useContext()
 	defer cancel()
 
-	cursor, err := menuItemCollection.Find(ctx, bson.M{"groupid": groupID})
+	cursor, err := menuItemCollection.Find(ctx, bson.M{"groupid": groupID, "deleted_at": nil}, options.Find().SetSort(helper.PositionSort))
 	if err != nil {
 		return nil, err
 	}
@@ -90,62 +117,99 @@ func ShowMenu() gin.HandlerFunc {
 			return
 		}
 
-		menuID := responseData.ID
+		at := time.Now()
+		if preview := c.Query("at"); preview != "" {
+			var err error
+			if at, err = time.Parse(time.RFC3339, preview); err != nil {
+				response := helper.ErrorResponse(nil, "at must be an RFC 3339 timestamp")
+				response.SendJSON(c.Writer, http.StatusBadRequest)
+				return
+			}
+		}
 
-		menu, err := getMenuByID(menuID)
-		if err != nil {
-			response := helper.ErrorResponse(nil, err.Error())
-			response.SendJSON(c.Writer, http.StatusInternalServerError)
+		ctx, cancel := useContext()
+		defer cancel()
+
+		menu, err := helper.LoadPublishedMenu(ctx, responseData.ID)
+		if err == mongo.ErrNoDocuments || err == helper.ErrNotPublished {
+			response := helper.NotFoundResponse(nil, "Menu not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
 			return
 		}
-
-		menuGroups, err := getMenuGroupByAll(menuID.Hex())
 		if err != nil {
 			response := helper.ErrorResponse(nil, err.Error())
 			response.SendJSON(c.Writer, http.StatusInternalServerError)
 			return
 		}
 
-		menuItems := make([][]models.MenuItem, len(menuGroups))
-		for i, group := range menuGroups {
-			groupID := group.ID.Hex()
-			menuItems[i], err = getMenuItemByID(groupID)
-			if err != nil {
-				response := helper.ErrorResponse(nil, err.Error())
-				response.SendJSON(c.Writer, http.StatusInternalServerError)
-				return
+		var table gin.H
+		if token := c.Query("table"); token != "" {
+			if found, err := helper.ResolveTableToken(ctx, token, menu.ID.Hex()); err == nil {
+				table = gin.H{
+					"id":     found.ID,
+					"number": found.Number,
+					"name":   found.Name,
+					"area":   found.Area,
+					"seats":  found.Seats,
+				}
 			}
 		}
 
-		menuGroupsArray := make([]gin.H, len(menuGroups))
-		for i, group := range menuGroups {
-			menuItemsArray := make([]gin.H, len(menuItems[i]))
-			for j, item := range menuItems[i] {
+		at = at.In(helper.MenuLocation(menu))
+		menuAvailable := helper.IsAvailable(menu.Schedule, at)
+
+		locales := helper.MenuLocales(menu)
+		locale := helper.NegotiateLocale(c.Query("lang"), c.GetHeader("Accept-Language"), locales)
+		currency := helper.MenuCurrency(menu)
+
+		menuGroupsArray := make([]gin.H, len(menu.MenuGroup))
+		for i, group := range menu.MenuGroup {
+			groupAvailable := menuAvailable && helper.IsAvailable(group.Schedule, at)
+			menuItemsArray := make([]gin.H, len(group.MenuItem))
+			for j, item := range group.MenuItem {
 				menuItem := gin.H{
-					"id":          item.ID,
-					"group_id":    item.GroupID,
-					"name":        item.Name,
-					"price":       item.Price,
-					"description": item.Description,
-					"image_url":   item.ImageURL,
+					"id":              item.ID,
+					"group_id":        item.GroupID,
+					"position":        item.Position,
+					"name":            helper.Localize(item.Name, item.Translations, locale, false),
+					"price":           item.Price,
+					"price_formatted": helper.FormatMoney(item.Price, currency, locale),
+					"description":     helper.Localize(item.Description, item.Translations, locale, true),
+					"image_url":       item.ImageURL,
+					"option_groups":   item.OptionGroup,
+					"available":       groupAvailable && helper.IsAvailable(item.Schedule, at),
+					"sold_out":        item.SoldOut,
+					"stock":           item.Stock,
 				}
 				menuItemsArray[j] = menuItem
 			}
 
 			menuGroup := gin.H{
-				"id":    group.ID,
-				"name":  group.Name,
-				"items": menuItemsArray,
+				"id":        group.ID,
+				"name":      helper.Localize(group.Name, group.Translations, locale, false),
+				"position":  group.Position,
+				"available": groupAvailable,
+				"items":     menuItemsArray,
 			}
 			menuGroupsArray[i] = menuGroup
 		}
 
 		response := gin.H{
-			"id":          menu.ID,
-			"name":        menu.Name,
-			"logo":        menu.Logo,
-			"banner":      menu.Banner,
-			"menu_groups": menuGroupsArray,
+			"id":             menu.ID,
+			"name":           helper.Localize(menu.Name, menu.Translations, locale, false),
+			"logo":           menu.Logo,
+			"banner":         menu.Banner,
+			"currency":       currency,
+			"timezone":       at.Location().String(),
+			"rendered_at":    at.Format(time.RFC3339),
+			"available":      menuAvailable,
+			"locale":         locale,
+			"default_locale": locales[0],
+			"locales":        locales,
+			"table":          table,
+			"version":        menu.PublishedVersion,
+			"published_at":   menu.PublishedAt,
+			"menu_groups":    menuGroupsArray,
 		}
 
 		successResponse := helper.SuccessResponse(response, "")
@@ -160,7 +224,7 @@ func GetMenu() gin.HandlerFunc {
 
 		userID := c.GetString("uid")
 
-		cursor, err := menuCollection.Find(ctx, bson.M{"userid": userID})
+		cursor, err := menuCollection.Find(ctx, bson.M{"userid": userID, "deleted_at": nil})
 		if err != nil {
 			response := helper.ErrorResponse(nil, err.Error())
 			response.SendJSON(c.Writer, http.StatusInternalServerError)
@@ -177,10 +241,12 @@ func GetMenu() gin.HandlerFunc {
 				return
 			}
 			responseItem := gin.H{
-				"id":     item.ID,
-				"name":   item.Name,
-				"logo":   item.Logo,
-				"banner": item.Banner,
+				"id":                item.ID,
+				"name":              item.Name,
+				"logo":              item.Logo,
+				"banner":            item.Banner,
+				"published_version": item.PublishedVersion,
+				"published_at":      item.PublishedAt,
 			}
 			items = append(items, responseItem)
 		}
@@ -191,8 +257,6 @@ func GetMenu() gin.HandlerFunc {
 }
 func AddUpdateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		middleware.Authenticate()(c)
-
 		userID := c.GetString("uid")
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -220,17 +284,39 @@ func AddUpdateMenu() gin.HandlerFunc {
 			return
 		}
 
+		if err := helper.ValidateSchedule(menu.Schedule); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
 		if menu.ID != primitive.NilObjectID {
-			filter := bson.M{"_id": menu.ID}
-			update := bson.M{
-				"$set": bson.M{
-					"name":       menu.Name,
-					"logo":       menu.Logo,
-					"banner":     menu.Banner,
-					"updated_at": time.Now(),
-				},
+			if !ownershipGranted(c, helper.CheckMenuOwner(c, menu.ID.Hex())) {
+				return
 			}
 
+			filter := bson.M{"_id": menu.ID, "deleted_at": nil}
+			fields := bson.M{
+				"name":       menu.Name,
+				"logo":       menu.Logo,
+				"banner":     menu.Banner,
+				"schedule":   menu.Schedule,
+				"updated_at": time.Now(),
+			}
+			if menu.Timezone != "" {
+				fields["timezone"] = menu.Timezone
+			}
+			if menu.Currency != "" {
+				fields["currency"] = menu.Currency
+			}
+			if menu.DefaultLocale != "" {
+				fields["defaultlocale"] = menu.DefaultLocale
+			}
+			if menu.Locales != nil {
+				fields["locales"] = menu.Locales
+			}
+			update := bson.M{"$set": fields}
+
 			updateResult, err := menuCollection.UpdateOne(ctx, filter, update)
 			if err != nil {
 				response := helper.ErrorResponse(nil, "Error while updating menu item")
@@ -244,6 +330,8 @@ func AddUpdateMenu() gin.HandlerFunc {
 				return
 			}
 
+			helper.PublishMenuEvent(ctx, "menu", "updated", menu.ID, menu)
+
 			responseData := gin.H{
 				"message":   "Menu item updated successfully",
 				"menu_item": menu,
@@ -253,9 +341,25 @@ func AddUpdateMenu() gin.HandlerFunc {
 			return
 		}
 
+		if helper.RequireEmailVerification() && !user.Email_verified {
+			response := helper.ForbiddenResponse(nil, "Verify your email before creating a menu")
+			response.SendJSON(c.Writer, http.StatusForbidden)
+			return
+		}
+
 		menu.ID = primitive.NewObjectID()
 		menu.UserID = &userID
 		menu.MenuGroup = make([]models.MenuGroup, 0)
+		menu.Currency = helper.MenuCurrency(menu)
+		if menu.Timezone == "" {
+			menu.Timezone = helper.DefaultTimezone
+		}
+		menu.DefaultLocale = helper.MenuLocales(menu)[0]
+		menu.Locales = helper.MenuLocales(menu)[1:]
+		menu.Translations = make(map[string]models.Translation)
+		menu.LatestVersion = 0
+		menu.PublishedVersion = 0
+		menu.PublishedAt = nil
 		menu.CreatedAt = time.Now()
 		menu.UpdatedAt = time.Now()
 
@@ -266,14 +370,14 @@ func AddUpdateMenu() gin.HandlerFunc {
 			return
 		}
 
+		helper.PublishMenuEvent(ctx, "menu", "created", menu.ID, menu)
+
 		response := helper.SuccessResponse(menu, "Menu added successfully")
 		response.SendJSON(c.Writer, http.StatusOK)
 	}
 }
 func DeleteMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		middleware.Authenticate()(c)
-
 		var menu models.Menu
 		if err := c.BindJSON(&menu); err != nil {
 			response := helper.ErrorResponse(nil, err.Error())
@@ -281,23 +385,27 @@ func DeleteMenu() gin.HandlerFunc {
 			return
 		}
 
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, menu.ID.Hex())) {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		filter := bson.M{"_id": menu.ID}
+		err := helper.TrashMenu(ctx, menu.ID)
+		if err == mongo.ErrNoDocuments {
+			response := helper.ErrorResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
 
-		deleteResult, err := menuCollection.DeleteOne(ctx, filter)
 		if err != nil {
 			response := helper.ErrorResponse(nil, "Error while deleting menu item")
 			response.SendJSON(c.Writer, http.StatusInternalServerError)
 			return
 		}
 
-		if deleteResult.DeletedCount == 0 {
-			response := helper.ErrorResponse(nil, "Menu item not found")
-			response.SendJSON(c.Writer, http.StatusNotFound)
-			return
-		}
+		helper.PublishMenuEvent(ctx, "menu", "deleted", menu.ID, nil)
 
 		response := helper.SuccessResponse(nil, "Menu item deleted successfully")
 		response.SendJSON(c.Writer, http.StatusOK)
@@ -315,6 +423,9 @@ func GetGroup() gin.HandlerFunc {
 		}
 
 		menuID := responseData.ID.Hex()
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, menuID)) {
+			return
+		}
 
 		data, err := getMenuGroupByAll(menuID)
 		if err != nil {
@@ -357,11 +468,22 @@ func AddUpdateGroup() gin.HandlerFunc {
 			return
 		}
 
+		if err := helper.ValidateSchedule(menuGroup.Schedule); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
 		if menuGroup.ID != primitive.NilObjectID {
-			filter := bson.M{"_id": menuGroup.ID}
+			if !ownershipGranted(c, helper.CheckGroupOwner(c, menuGroup.ID.Hex())) {
+				return
+			}
+
+			filter := bson.M{"_id": menuGroup.ID, "deleted_at": nil}
 			update := bson.M{
 				"$set": bson.M{
 					"name":       menuGroup.Name,
+					"schedule":   menuGroup.Schedule,
 					"updated_at": time.Now(),
 				},
 			}
@@ -379,6 +501,8 @@ func AddUpdateGroup() gin.HandlerFunc {
 				return
 			}
 
+			helper.PublishMenuEvent(ctx, "group", "updated", menuGroup.ID, menuGroup)
+
 			responseData := gin.H{
 				"message":   "Menu item updated successfully",
 				"menu_item": menuGroup,
@@ -388,8 +512,21 @@ func AddUpdateGroup() gin.HandlerFunc {
 			return
 		}
 
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, *menuGroup.MenuID)) {
+			return
+		}
+
+		position, err := helper.NextPosition(ctx, menuGroupCollection, "menuid", *menuGroup.MenuID)
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while adding menu item")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
 		menuGroup.ID = primitive.NewObjectID()
+		menuGroup.Position = position
 		menuGroup.MenuItem = make([]models.MenuItem, 0)
+		menuGroup.Translations = make(map[string]models.Translation)
 		menuGroup.CreatedAt = time.Now()
 		menuGroup.UpdatedAt = time.Now()
 
@@ -400,14 +537,14 @@ func AddUpdateGroup() gin.HandlerFunc {
 			return
 		}
 
+		helper.PublishMenuEvent(ctx, "group", "created", menuGroup.ID, menuGroup)
+
 		response := helper.SuccessResponse(menuGroup, "")
 		response.SendJSON(c.Writer, http.StatusOK)
 	}
 }
 func DeleteGroup() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		middleware.Authenticate()(c)
-
 		var menuGroup models.MenuGroup
 		if err := c.BindJSON(&menuGroup); err != nil {
 			response := helper.ErrorResponse(nil, err.Error())
@@ -415,23 +552,27 @@ func DeleteGroup() gin.HandlerFunc {
 			return
 		}
 
+		if !ownershipGranted(c, helper.CheckGroupOwner(c, menuGroup.ID.Hex())) {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		filter := bson.M{"_id": menuGroup.ID}
+		err := helper.TrashGroup(ctx, menuGroup.ID)
+		if err == mongo.ErrNoDocuments {
+			response := helper.ErrorResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
 
-		deleteResult, err := menuGroupCollection.DeleteOne(ctx, filter)
 		if err != nil {
 			response := helper.ErrorResponse(nil, "Error while deleting menu item")
 			response.SendJSON(c.Writer, http.StatusInternalServerError)
 			return
 		}
 
-		if deleteResult.DeletedCount == 0 {
-			response := helper.ErrorResponse(nil, "Menu item not found")
-			response.SendJSON(c.Writer, http.StatusNotFound)
-			return
-		}
+		helper.PublishMenuEvent(ctx, "group", "deleted", menuGroup.ID, nil)
 
 		response := helper.SuccessResponse(nil, "Menu item deleted successfully")
 		response.SendJSON(c.Writer, http.StatusOK)
@@ -449,6 +590,9 @@ func GetItem() gin.HandlerFunc {
 		}
 
 		menuGroupID := responseData.ID.Hex()
+		if !ownershipGranted(c, helper.CheckGroupOwner(c, menuGroupID)) {
+			return
+		}
 
 		data, err := getMenuItemByID(menuGroupID)
 		if err != nil {
@@ -463,8 +607,6 @@ func GetItem() gin.HandlerFunc {
 }
 func AddUpdateItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		middleware.Authenticate()(c)
-
 		userID := c.GetString("uid")
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -492,14 +634,39 @@ func AddUpdateItem() gin.HandlerFunc {
 			return
 		}
 
+		if err := helper.ValidateSchedule(menuItem.Schedule); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if menuItem.OptionGroup == nil {
+			menuItem.OptionGroup = make([]models.OptionGroup, 0)
+		}
+		if err := helper.PrepareOptionGroups(menuItem.OptionGroup); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
 		if menuItem.ID != primitive.NilObjectID {
-			filter := bson.M{"_id": menuItem.ID}
+			if !ownershipGranted(c, helper.CheckItemOwner(c, menuItem.ID.Hex())) {
+				return
+			}
+
+			if !itemPricesValid(ctx, c, menuItem, "item", menuItem.ID) {
+				return
+			}
+
+			filter := bson.M{"_id": menuItem.ID, "deleted_at": nil}
 			update := bson.M{
 				"$set": bson.M{
 					"name":        menuItem.Name,
 					"description": menuItem.Description,
 					"price":       menuItem.Price,
 					"imageurl":    menuItem.ImageURL,
+					"optiongroup": menuItem.OptionGroup,
+					"schedule":    menuItem.Schedule,
 					"updated_at":  time.Now(),
 				},
 			}
@@ -517,6 +684,8 @@ func AddUpdateItem() gin.HandlerFunc {
 				return
 			}
 
+			helper.PublishMenuEvent(ctx, "item", "updated", menuItem.ID, menuItem)
+
 			responseData := gin.H{
 				"message":   "Menu item updated successfully",
 				"menu_item": menuItem,
@@ -526,7 +695,35 @@ func AddUpdateItem() gin.HandlerFunc {
 			return
 		}
 
+		if menuItem.GroupID == nil {
+			response := helper.ErrorResponse(nil, "group_id is required")
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentGroupOwner(c, *menuItem.GroupID)) {
+			return
+		}
+
+		groupID, _ := primitive.ObjectIDFromHex(*menuItem.GroupID)
+		if !itemPricesValid(ctx, c, menuItem, "group", groupID) {
+			return
+		}
+
+		position, err := helper.NextPosition(ctx, menuItemCollection, "groupid", *menuItem.GroupID)
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while adding menu item")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
 		menuItem.ID = primitive.NewObjectID()
+		menuItem.Position = position
+		menuItem.Translations = make(map[string]models.Translation)
+		if menuItem.Stock != nil && *menuItem.Stock == 0 {
+			menuItem.SoldOut = true
+			menuItem.SoldOutByStock = true
+		}
 		menuItem.CreatedAt = time.Now()
 		menuItem.UpdatedAt = time.Now()
 
@@ -537,6 +734,8 @@ func AddUpdateItem() gin.HandlerFunc {
 			return
 		}
 
+		helper.PublishMenuEvent(ctx, "item", "created", menuItem.ID, menuItem)
+
 		responseData := gin.H{
 			"message":   "Menu item added successfully",
 			"menu_item": menuItem,
@@ -547,8 +746,6 @@ func AddUpdateItem() gin.HandlerFunc {
 }
 func DeleteItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		middleware.Authenticate()(c)
-
 		var menuItem models.MenuItem
 		if err := c.BindJSON(&menuItem); err != nil {
 			response := helper.ErrorResponse(nil, err.Error())
@@ -556,25 +753,426 @@ func DeleteItem() gin.HandlerFunc {
 			return
 		}
 
+		if !ownershipGranted(c, helper.CheckItemOwner(c, menuItem.ID.Hex())) {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		filter := bson.M{"_id": menuItem.ID}
+		err := helper.TrashItem(ctx, menuItem.ID)
+		if err == mongo.ErrNoDocuments {
+			response := helper.ErrorResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
 
-		deleteResult, err := menuItemCollection.DeleteOne(ctx, filter)
 		if err != nil {
 			response := helper.ErrorResponse(nil, "Error while deleting menu item")
 			response.SendJSON(c.Writer, http.StatusInternalServerError)
 			return
 		}
 
-		if deleteResult.DeletedCount == 0 {
-			response := helper.ErrorResponse(nil, "Menu item not found")
+		helper.PublishMenuEvent(ctx, "item", "deleted", menuItem.ID, nil)
+
+		response := helper.SuccessResponse(nil, "Menu item deleted successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func GetTrash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := useContext()
+		defer cancel()
+
+		trash, err := helper.ListTrash(ctx, c.GetString("uid"))
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(trash, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func RestoreTrash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Type string             `json:"type" validate:"required,eq=menu|eq=group|eq=item"`
+			ID   primitive.ObjectID `json:"id"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		var err error
+		switch request.Type {
+		case "menu":
+			if !ownershipGranted(c, helper.CheckMenuOwner(c, request.ID.Hex())) {
+				return
+			}
+			err = helper.RestoreMenu(ctx, request.ID)
+		case "group":
+			if !ownershipGranted(c, helper.CheckGroupOwner(c, request.ID.Hex())) {
+				return
+			}
+			err = helper.RestoreGroup(ctx, request.ID)
+		case "item":
+			if !ownershipGranted(c, helper.CheckItemOwner(c, request.ID.Hex())) {
+				return
+			}
+			err = helper.RestoreItem(ctx, request.ID)
+		}
+
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Not found in the trash")
 			response.SendJSON(c.Writer, http.StatusNotFound)
 			return
 		}
 
-		response := helper.SuccessResponse(nil, "Menu item deleted successfully")
+		if err == helper.ErrParentDeleted {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while restoring")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishMenuEvent(ctx, request.Type, "created", request.ID, nil)
+
+		response := helper.SuccessResponse(nil, "Restored successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+type reorderRequest struct {
+	ParentID string               `json:"parent_id" validate:"required"`
+	IDs      []primitive.ObjectID `json:"ids" validate:"required"`
+}
+
+func reorderResponse(c *gin.Context, err error) {
+	if err == helper.ErrInvalidOrder {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		response := helper.ErrorResponse(nil, "Error while saving the order")
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+		return
+	}
+
+	response := helper.SuccessResponse(nil, "Order saved successfully")
+	response.SendJSON(c.Writer, http.StatusOK)
+}
+
+func ReorderGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request reorderRequest
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, request.ParentID)) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err := helper.ReorderGroups(ctx, request.ParentID, request.IDs)
+		if err == nil {
+			menuID, _ := primitive.ObjectIDFromHex(request.ParentID)
+			helper.PublishMenuEvent(ctx, "menu", "updated", menuID, gin.H{"group_ids": request.IDs})
+		}
+		reorderResponse(c, err)
+	}
+}
+
+func ReorderItems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request reorderRequest
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentGroupOwner(c, request.ParentID)) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err := helper.ReorderItems(ctx, request.ParentID, request.IDs)
+		if err == nil {
+			groupID, _ := primitive.ObjectIDFromHex(request.ParentID)
+			helper.PublishMenuEvent(ctx, "group", "updated", groupID, gin.H{"item_ids": request.IDs})
+		}
+		reorderResponse(c, err)
+	}
+}
+
+func ItemPrice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ItemID  primitive.ObjectID   `json:"item_id"`
+			Options []primitive.ObjectID `json:"options"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		draft, err := helper.MenuOf(ctx, "item", request.ItemID)
+		if err != nil && err != mongo.ErrNoDocuments {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		var menu models.Menu
+		if err == nil {
+			menu, err = helper.LoadPublishedMenu(ctx, draft.ID)
+		}
+		if err != nil && err != mongo.ErrNoDocuments && err != helper.ErrNotPublished {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		_, item, found := helper.FindPublishedItem(menu, request.ItemID)
+		if err != nil || !found {
+			response := helper.NotFoundResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+
+		price, selected, err := helper.PriceForSelection(item, request.Options)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		currency := helper.MenuCurrency(menu)
+		locale := helper.NegotiateLocale(c.Query("lang"), c.GetHeader("Accept-Language"), helper.MenuLocales(menu))
+
+		responseData := gin.H{
+			"item_id":         item.ID,
+			"base_price":      item.Price,
+			"options":         selected,
+			"price":           price,
+			"price_formatted": helper.FormatMoney(price, currency, locale),
+			"currency":        currency,
+		}
+		response := helper.SuccessResponse(responseData, "")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+type translationRequest struct {
+	Type        string             `json:"type" validate:"required,eq=menu|eq=group|eq=item"`
+	ID          primitive.ObjectID `json:"id"`
+	Locale      string             `json:"locale" validate:"required,bcp47_language_tag"`
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+}
+
+func translationOwnershipGranted(c *gin.Context, request translationRequest) bool {
+	switch request.Type {
+	case "menu":
+		return ownershipGranted(c, helper.CheckMenuOwner(c, request.ID.Hex()))
+	case "group":
+		return ownershipGranted(c, helper.CheckGroupOwner(c, request.ID.Hex()))
+	}
+	return ownershipGranted(c, helper.CheckItemOwner(c, request.ID.Hex()))
+}
+
+func AddUpdateTranslation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request translationRequest
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !translationOwnershipGranted(c, request) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		translation := models.Translation{Name: request.Name, Description: request.Description}
+		err := helper.SetTranslation(ctx, request.Type, request.ID, request.Locale, translation)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err == helper.ErrDefaultLocale {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while saving the translation")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishMenuEvent(ctx, request.Type, "updated", request.ID, gin.H{"locale": request.Locale, "translation": translation})
+
+		response := helper.SuccessResponse(translation, "Translation saved successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func DeleteTranslation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request translationRequest
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !translationOwnershipGranted(c, request) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err := helper.DeleteTranslation(ctx, request.Type, request.ID, request.Locale)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while deleting the translation")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishMenuEvent(ctx, request.Type, "updated", request.ID, gin.H{"locale": request.Locale, "translation": nil})
+
+		response := helper.SuccessResponse(nil, "Translation deleted successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func UpdateItemStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID           primitive.ObjectID `json:"id"`
+			SoldOut      *bool              `json:"sold_out"`
+			Stock        *int64             `json:"stock" validate:"omitempty,gte=0"`
+			UntrackStock bool               `json:"untrack_stock"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckItemOwner(c, request.ID.Hex())) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err := helper.SetStock(ctx, request.ID, request.SoldOut, request.Stock, request.UntrackStock)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Menu item not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while updating the stock")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		var menuItem models.MenuItem
+		if err := menuItemCollection.FindOne(ctx, bson.M{"_id": request.ID}).Decode(&menuItem); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		responseData := gin.H{
+			"id":       menuItem.ID,
+			"sold_out": menuItem.SoldOut,
+			"stock":    menuItem.Stock,
+		}
+		helper.PublishLiveMenuEvent(ctx, "item", "updated", menuItem.ID, responseData)
+
+		response := helper.SuccessResponse(responseData, "Stock updated successfully")
 		response.SendJSON(c.Writer, http.StatusOK)
 	}
 }
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/models"
+)
+
+func TestMenuItemPriceIsRequired(t *testing.T) {
+	name, description, image := "Soup", "Lentil soup", "soup.png"
+	item := models.MenuItem{Name: &name, Description: &description, ImageURL: &image}
+	if err := validate.Struct(item); err == nil {
+		t.Error("an item without a price passed validation")
+	}
+
+	for _, amount := range []string{"0", "12.50"} {
+		price, err := models.ParseMoney(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item.Price = price
+		if err := validate.Struct(item); err != nil {
+			t.Errorf("price %s: %v", amount, err)
+		}
+	}
+}
+
+func TestAddUpdateItemRejectsMissingPrice(t *testing.T) {
+	requireDatabase(t)
+	user := seedUser(t, models.User{Email_verified: true})
+	item := gin.H{"group_id": "000000000000000000000000", "name": "Soup", "description": "Lentil soup", "image_url": "soup.png"}
+
+	w := postJSON(AddUpdateItem(), item, accessToken(t, user))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+}
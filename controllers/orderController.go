@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+
+func SubmitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID primitive.ObjectID `json:"menu_id"`
+			Table  string             `json:"table"`
+			Items  []helper.OrderLine `json:"items" validate:"required,min=1,max=50,dive"`
+			Note   *string            `json:"note" validate:"omitempty,max=500"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		order, err := helper.BuildOrder(ctx, request.MenuID, request.Items, time.Now())
+		if err == helper.ErrNotFound {
+			response := helper.NotFoundResponse(nil, "Menu not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, helper.ErrOrderRejected) {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if request.Table != "" {
+			table, err := helper.ResolveTableToken(ctx, request.Table, order.MenuID)
+			if err == helper.ErrInvalidTableToken {
+				response := helper.ErrorResponse(nil, err.Error())
+				response.SendJSON(c.Writer, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				response := helper.ErrorResponse(nil, err.Error())
+				response.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+			tableID := table.ID.Hex()
+			order.TableID = &tableID
+			order.TableName = table.Name
+		}
+		order.Note = request.Note
+
+		err = helper.PlaceOrder(ctx, &order)
+		if errors.Is(err, helper.ErrOrderRejected) {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while placing order")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(order, "Order received")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func ListOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID string `json:"menu_id" validate:"required"`
+			Status string `json:"status" validate:"omitempty,oneof=received preparing ready served paid cancelled"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, request.MenuID)) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		filter := bson.M{"menuid": request.MenuID}
+		if request.Status != "" {
+			filter["status"] = request.Status
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+		cursor, err := orderCollection.Find(ctx, filter, opts)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		defer cursor.Close(ctx)
+
+		orders := make([]models.Order, 0)
+		if err := cursor.All(ctx, &orders); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(orders, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func UpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID     primitive.ObjectID `json:"id"`
+			Status string             `json:"status" validate:"required,oneof=preparing ready served paid cancelled"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		var order models.Order
+		err := orderCollection.FindOne(ctx, bson.M{"_id": request.ID}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Order not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, order.MenuID)) {
+			return
+		}
+
+		order, err = helper.SetOrderStatus(ctx, order, request.Status, c.GetString("uid"))
+		if err == helper.ErrInvalidTransition {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while updating order")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(order, "Order updated successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
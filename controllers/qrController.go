@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// qrRequest loads the menu and reads the rendering options from the query string.
+// It sends the error response itself and returns false on failure.
+func qrRequest(c *gin.Context, menuHex string) (models.Menu, helper.QROptions, bool) {
+	var menu models.Menu
+	opts := helper.QROptions{Size: 512}
+
+	menuID, err := primitive.ObjectIDFromHex(menuHex)
+	if err != nil {
+		response := helper.NotFoundResponse(nil, "Menu not found")
+		response.SendJSON(c.Writer, http.StatusNotFound)
+		return menu, opts, false
+	}
+
+	ctx, cancel := useContext()
+	defer cancel()
+
+	err = menuCollection.FindOne(ctx, bson.M{"_id": menuID, "deleted_at": nil}).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		response := helper.NotFoundResponse(nil, "Menu not found")
+		response.SendJSON(c.Writer, http.StatusNotFound)
+		return menu, opts, false
+	}
+	if err != nil {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+		return menu, opts, false
+	}
+
+	if size := c.Query("size"); size != "" {
+		opts.Size, err = strconv.Atoi(size)
+		if err != nil || opts.Size < 128 || opts.Size > 2048 {
+			response := helper.ErrorResponse(nil, "size must be between 128 and 2048")
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return menu, opts, false
+		}
+	}
+
+	if opts.Level, err = helper.ParseRecoveryLevel(c.Query("level")); err != nil {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+		return menu, opts, false
+	}
+
+	if fg := c.Query("fg"); fg != "" {
+		if opts.Foreground, err = helper.ParseHexColor(fg); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return menu, opts, false
+		}
+	}
+	if bg := c.Query("bg"); bg != "" {
+		if opts.Background, err = helper.ParseHexColor(bg); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return menu, opts, false
+		}
+	}
+
+	if c.Query("logo") == "true" && menu.Logo != nil && *menu.Logo != "" {
+		if opts.Logo, err = helper.FetchLogo(*menu.Logo); err != nil {
+			response := helper.ErrorResponse(nil, "Logo could not be loaded: "+err.Error())
+			response.SendJSON(c.Writer, http.StatusBadGateway)
+			return menu, opts, false
+		}
+	}
+
+	return menu, opts, true
+}
+
+// sendQRCode renders content in the format asked for by the query string.
+func sendQRCode(c *gin.Context, content string, opts helper.QROptions) {
+	switch c.DefaultQuery("format", "png") {
+	case "png":
+		data, err := helper.QRCodePNG(content, opts)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "image/png", data)
+	case "svg":
+		data, err := helper.QRCodeSVG(content, opts)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml", data)
+	default:
+		response := helper.ErrorResponse(nil, "format must be png or svg")
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+	}
+}
+
+// MenuQRCode renders the public address of a menu. It is public, so it never
+// contains a table token.
+func MenuQRCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		menu, opts, ok := qrRequest(c, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		sendQRCode(c, helper.MenuURL(menu.ID.Hex()), opts)
+	}
+}
+
+// TableQRCode renders the address of a table, including its signed token. Only
+// the owner of the menu may see it.
+func TableQRCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table models.Table
+		tableID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response := helper.NotFoundResponse(nil, "Table not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err = tableCollection.FindOne(ctx, bson.M{"_id": tableID}).Decode(&table)
+		if err == mongo.ErrNoDocuments || (err == nil && table.MenuID == nil) {
+			response := helper.NotFoundResponse(nil, "Table not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, *table.MenuID)) {
+			return
+		}
+
+		_, opts, ok := qrRequest(c, *table.MenuID)
+		if !ok {
+			return
+		}
+
+		sendQRCode(c, helper.TableURL(table), opts)
+	}
+}
+
+// MenuQRSheet renders a printable sheet with the codes of all tables of a menu.
+// Only the owner of the menu may see it.
+func MenuQRSheet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, c.Param("id"))) {
+			return
+		}
+
+		menu, opts, ok := qrRequest(c, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		tables, err := getTablesByMenu(menu.ID.Hex())
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if len(tables) == 0 {
+			response := helper.NotFoundResponse(nil, "Menu has no tables")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+
+		entries := make([]helper.QRSheetEntry, len(tables))
+		for i, table := range tables {
+			label := *table.Name
+			if table.Area != nil && *table.Area != "" {
+				label = fmt.Sprintf("%s (%s)", label, *table.Area)
+			}
+			entries[i] = helper.QRSheetEntry{Label: label, Content: helper.TableURL(table)}
+		}
+
+		data, err := helper.QRSheetPDF(*menu.Name, entries, opts)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="qr-%s.pdf"`, menu.ID.Hex()))
+		c.Data(http.StatusOK, "application/pdf", data)
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sendScheduleError answers the errors shared by scheduling and rescheduling.
+func sendScheduleError(c *gin.Context, err error, message string) {
+	switch err {
+	case mongo.ErrNoDocuments:
+		response := helper.NotFoundResponse(nil, "Menu not found")
+		response.SendJSON(c.Writer, http.StatusNotFound)
+	case helper.ErrScheduleInPast:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+	case helper.ErrScheduleClosed:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusConflict)
+	default:
+		response := helper.ErrorResponse(nil, message)
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+	}
+}
+
+// SchedulePublish publishes the current draft at publish_at, a local time in the
+// menu's timezone such as "2024-09-01 06:00".
+func SchedulePublish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID    primitive.ObjectID `json:"menu_id"`
+			PublishAt string             `json:"publish_at" validate:"required,datetime=2006-01-02 15:04"`
+			Note      *string            `json:"note" validate:"omitempty,max=200"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		scheduled, err := helper.SchedulePublish(ctx, request.MenuID, c.GetString("uid"), request.PublishAt, request.Note)
+		if err != nil {
+			sendScheduleError(c, err, "Error while scheduling the publish")
+			return
+		}
+
+		helper.PublishMenuEvent(ctx, "menu", "scheduled", request.MenuID, gin.H{"id": scheduled.ID, "publish_at": scheduled.PublishAt})
+
+		scheduled.Menu = models.Menu{}
+		response := helper.SuccessResponse(scheduled, "Publish scheduled successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func GetScheduledPublishes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID primitive.ObjectID `json:"menu_id"`
+			Status string             `json:"status" validate:"omitempty,oneof=pending running published cancelled failed"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		scheduled, err := helper.ListScheduledPublishes(ctx, request.MenuID.Hex(), request.Status)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(scheduled, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// findOwnedScheduledPublish loads the scheduled publish and checks that the user
+// owns its menu. It sends the error response itself and returns false on failure.
+func findOwnedScheduledPublish(c *gin.Context, ctx context.Context, id primitive.ObjectID) (models.ScheduledPublish, bool) {
+	scheduled, err := helper.FindScheduledPublish(ctx, id)
+	if err == mongo.ErrNoDocuments {
+		response := helper.NotFoundResponse(nil, "Scheduled publish not found")
+		response.SendJSON(c.Writer, http.StatusNotFound)
+		return scheduled, false
+	}
+	if err != nil {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+		return scheduled, false
+	}
+
+	return scheduled, ownershipGranted(c, helper.CheckMenuOwner(c, scheduled.MenuID))
+}
+
+func ReschedulePublish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID        primitive.ObjectID `json:"id"`
+			PublishAt string             `json:"publish_at" validate:"required,datetime=2006-01-02 15:04"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		scheduled, found := findOwnedScheduledPublish(c, ctx, request.ID)
+		if !found {
+			return
+		}
+
+		scheduled, err := helper.ReschedulePublish(ctx, scheduled, request.PublishAt)
+		if err != nil {
+			sendScheduleError(c, err, "Error while rescheduling the publish")
+			return
+		}
+
+		response := helper.SuccessResponse(scheduled, "Publish rescheduled successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func CancelScheduledPublish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID primitive.ObjectID `json:"id"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		if _, found := findOwnedScheduledPublish(c, ctx, request.ID); !found {
+			return
+		}
+
+		scheduled, err := helper.CancelScheduledPublish(ctx, request.ID)
+		if err != nil {
+			sendScheduleError(c, err, "Error while cancelling the publish")
+			return
+		}
+
+		response := helper.SuccessResponse(scheduled, "Scheduled publish cancelled")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CallService records a "call waiter" or "bring the bill" request from a table.
+// Each client address may call from a table once per cooldown.
+func CallService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID string  `json:"menu_id" validate:"required"`
+			Table  string  `json:"table" validate:"required"`
+			Type   string  `json:"type" validate:"required,oneof=waiter bill"`
+			Note   *string `json:"note" validate:"omitempty,max=200"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		table, err := helper.ResolveTableToken(ctx, request.Table, request.MenuID)
+		if err == helper.ErrInvalidTableToken {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		callerKey := helper.ServiceCallerKey(table.ID.Hex(), c.ClientIP())
+		wait, err := helper.ServiceCallWait(ctx, table.ID.Hex(), callerKey)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		if wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			response := helper.TooManyRequestsResponse(nil, fmt.Sprintf("Please wait %d seconds before calling again", seconds))
+			response.SendJSON(c.Writer, http.StatusTooManyRequests)
+			return
+		}
+
+		serviceRequest, created, err := helper.CreateServiceRequest(ctx, models.ServiceRequest{
+			MenuID:    request.MenuID,
+			TableID:   table.ID.Hex(),
+			TableName: table.Name,
+			Type:      request.Type,
+			Note:      request.Note,
+			DeviceID:  callerKey,
+		})
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while sending the request")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !created {
+			response := helper.SuccessResponse(serviceRequest, "The staff has already been called")
+			response.SendJSON(c.Writer, http.StatusOK)
+			return
+		}
+
+		helper.PublishServiceEvent(ctx, "created", serviceRequest)
+
+		response := helper.SuccessResponse(serviceRequest, "The staff has been called")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func GetServiceRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID string `json:"menu_id" validate:"required"`
+			Status string `json:"status" validate:"omitempty,oneof=open acknowledged resolved"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, request.MenuID)) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		requests, err := helper.ListServiceRequests(ctx, request.MenuID, request.Status)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(requests, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+type serviceUpdate func(ctx context.Context, id primitive.ObjectID, userID string) (models.ServiceRequest, error)
+
+// updateServiceRequest applies a staff action to the call named in the body after
+// checking that the user owns its menu.
+func updateServiceRequest(action string, message string, update serviceUpdate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID primitive.ObjectID `json:"id"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		serviceRequest, err := helper.FindServiceRequest(ctx, request.ID)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Request not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckMenuOwner(c, serviceRequest.MenuID)) {
+			return
+		}
+
+		serviceRequest, err = update(ctx, request.ID, c.GetString("uid"))
+		if err == helper.ErrServiceClosed {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while updating the request")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishServiceEvent(ctx, action, serviceRequest)
+
+		response := helper.SuccessResponse(serviceRequest, message)
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func AcknowledgeServiceRequest() gin.HandlerFunc {
+	return updateServiceRequest("acknowledged", "Request acknowledged", helper.AcknowledgeServiceRequest)
+}
+
+func ResolveServiceRequest() gin.HandlerFunc {
+	return updateServiceRequest("resolved", "Request resolved", helper.ResolveServiceRequest)
+}
@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+
+const maxBulkTables = 200
+
+func tableResponse(table models.Table) gin.H {
+	return gin.H{
+		"id":      table.ID,
+		"menu_id": table.MenuID,
+		"number":  table.Number,
+		"name":    table.Name,
+		"area":    table.Area,
+		"seats":   table.Seats,
+		"token":   helper.TableToken(table),
+		"url":     helper.TableURL(table),
+	}
+}
+
+func getTablesByMenu(menuID string) ([]models.Table, error) {
+	ctx, cancel := useContext()
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := tableCollection.Find(ctx, bson.M{"menuid": menuID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tables := make([]models.Table, 0)
+	if err := cursor.All(ctx, &tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func GetTables() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.Table
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if request.MenuID == nil {
+			response := helper.ErrorResponse(nil, "menu_id is required")
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, *request.MenuID)) {
+			return
+		}
+
+		tables, err := getTablesByMenu(*request.MenuID)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		items := make([]gin.H, len(tables))
+		for i, table := range tables {
+			items[i] = tableResponse(table)
+		}
+
+		successResponse := helper.SuccessResponse(items, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func AddUpdateTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table models.Table
+		if err := c.BindJSON(&table); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(table)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, *table.MenuID)) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		if table.ID != primitive.NilObjectID {
+			filter := bson.M{"_id": table.ID, "menuid": table.MenuID}
+			update := bson.M{
+				"$set": bson.M{
+					"number":    table.Number,
+					"name":      table.Name,
+					"area":      table.Area,
+					"seats":     table.Seats,
+					"updatedat": time.Now(),
+				},
+			}
+
+			updateResult, err := tableCollection.UpdateOne(ctx, filter, update)
+			if mongo.IsDuplicateKeyError(err) {
+				response := helper.ErrorResponse(nil, helper.ErrTableNumberTaken.Error())
+				response.SendJSON(c.Writer, http.StatusConflict)
+				return
+			}
+			if err != nil {
+				response := helper.ErrorResponse(nil, "Error while updating table")
+				response.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+
+			if updateResult.MatchedCount == 0 {
+				response := helper.NotFoundResponse(nil, "Table not found")
+				response.SendJSON(c.Writer, http.StatusNotFound)
+				return
+			}
+
+			response := helper.SuccessResponse(tableResponse(table), "Table updated successfully")
+			response.SendJSON(c.Writer, http.StatusOK)
+			return
+		}
+
+		table.ID = primitive.NewObjectID()
+		table.CreatedAt = time.Now()
+		table.UpdatedAt = time.Now()
+
+		_, err := tableCollection.InsertOne(ctx, table)
+		if mongo.IsDuplicateKeyError(err) {
+			response := helper.ErrorResponse(nil, helper.ErrTableNumberTaken.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while adding table")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(tableResponse(table), "Table added successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func DeleteTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table models.Table
+		if err := c.BindJSON(&table); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		var found models.Table
+		err := tableCollection.FindOne(ctx, bson.M{"_id": table.ID}).Decode(&found)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Table not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, *found.MenuID)) {
+			return
+		}
+
+		if _, err := tableCollection.DeleteOne(ctx, bson.M{"_id": found.ID}); err != nil {
+			response := helper.ErrorResponse(nil, "Error while deleting table")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(nil, "Table deleted successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// BulkCreateTables creates the tables numbered From to To, named Prefix followed by
+// the number. Numbers that already exist on the menu are skipped.
+func BulkCreateTables() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID string  `json:"menu_id" validate:"required"`
+			From   int     `json:"from" validate:"gte=1"`
+			To     int     `json:"to" validate:"gtefield=From"`
+			Prefix string  `json:"prefix" validate:"max=40"`
+			Area   *string `json:"area" validate:"omitempty,max=50"`
+			Seats  int     `json:"seats" validate:"gte=0,lte=100"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if request.To-request.From+1 > maxBulkTables {
+			response := helper.ErrorResponse(nil, fmt.Sprintf("At most %d tables can be created at once", maxBulkTables))
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if !ownershipGranted(c, helper.CheckParentMenuOwner(c, request.MenuID)) {
+			return
+		}
+
+		existing, err := getTablesByMenu(request.MenuID)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		taken := make(map[int]bool, len(existing))
+		for _, table := range existing {
+			taken[table.Number] = true
+		}
+
+		prefix := strings.TrimSpace(request.Prefix)
+		if prefix == "" {
+			prefix = "Table"
+		}
+
+		tables := make([]interface{}, 0, request.To-request.From+1)
+		created := make([]gin.H, 0, request.To-request.From+1)
+		for number := request.From; number <= request.To; number++ {
+			if taken[number] {
+				continue
+			}
+
+			menuID := request.MenuID
+			name := fmt.Sprintf("%s %d", prefix, number)
+			table := models.Table{
+				ID:        primitive.NewObjectID(),
+				MenuID:    &menuID,
+				Number:    number,
+				Name:      &name,
+				Area:      request.Area,
+				Seats:     request.Seats,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+			tables = append(tables, table)
+			created = append(created, tableResponse(table))
+		}
+
+		if len(tables) > 0 {
+			ctx, cancel := useContext()
+			defer cancel()
+
+			_, err := tableCollection.InsertMany(ctx, tables)
+			if mongo.IsDuplicateKeyError(err) {
+				// Another request took one of the numbers after they were read.
+				response := helper.ErrorResponse(nil, helper.ErrTableNumberTaken.Error())
+				response.SendJSON(c.Writer, http.StatusConflict)
+				return
+			}
+			if err != nil {
+				response := helper.ErrorResponse(nil, "Error while adding tables")
+				response.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		response := helper.SuccessResponse(created, fmt.Sprintf("%d tables added", len(created)))
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"image/color"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type twoFactorRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// loadTwoFactorUser loads the signed in user and, when request is not nil, reads
+// and validates the body. It sends the error response itself and returns false
+// on failure.
+func loadTwoFactorUser(c *gin.Context, request *twoFactorRequest) (models.User, bool) {
+	var user models.User
+	if request != nil {
+		if err := c.BindJSON(request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return user, false
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return user, false
+		}
+	}
+
+	ctx, cancel := useContext()
+	defer cancel()
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user); err != nil {
+		response := helper.UnauthorizedResponse(nil, "user not found")
+		response.SendJSON(c.Writer, http.StatusUnauthorized)
+		return user, false
+	}
+	return user, true
+}
+
+// sendTwoFactorError answers the errors shared by the two-factor endpoints.
+func sendTwoFactorError(c *gin.Context, err error) {
+	switch err {
+	case helper.ErrInvalidTOTPCode:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+	case helper.ErrTOTPAlreadyEnabled, helper.ErrTOTPNotEnabled, helper.ErrTOTPNotSetUp:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusConflict)
+	default:
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusInternalServerError)
+	}
+}
+
+// SetupTwoFactor creates a new secret and returns it with the provisioning URI
+// and a QR code of it for authenticator apps. Two-factor authentication is off
+// until EnableTwoFactor confirms a code.
+func SetupTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, found := loadTwoFactorUser(c, nil)
+		if !found {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		secret, err := helper.StartTOTPSetup(ctx, user)
+		if err != nil {
+			sendTwoFactorError(c, err)
+			return
+		}
+
+		uri := helper.TOTPProvisioningURI(secret, *user.Email)
+		png, err := helper.QRCodePNG(uri, helper.QROptions{
+			Size:       256,
+			Level:      qrcode.Medium,
+			Foreground: color.Black,
+			Background: color.White,
+		})
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		responseData := gin.H{
+			"secret":  secret,
+			"uri":     uri,
+			"qr_code": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		}
+		response := helper.SuccessResponse(responseData, "Scan the code and confirm it with a code from your app")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// EnableTwoFactor turns two-factor authentication on and returns the recovery
+// codes, which are not shown again.
+func EnableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request twoFactorRequest
+		user, found := loadTwoFactorUser(c, &request)
+		if !found {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		codes, err := helper.EnableTOTP(ctx, user, request.Code)
+		if err != nil {
+			sendTwoFactorError(c, err)
+			return
+		}
+
+		response := helper.SuccessResponse(gin.H{"recovery_codes": codes}, "Two-factor authentication enabled")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func DisableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request twoFactorRequest
+		user, found := loadTwoFactorUser(c, &request)
+		if !found {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		if err := helper.DisableTOTP(ctx, user, request.Code); err != nil {
+			sendTwoFactorError(c, err)
+			return
+		}
+
+		response := helper.SuccessResponse(nil, "Two-factor authentication disabled")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, for example after some of
+// them were used.
+func RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request twoFactorRequest
+		user, found := loadTwoFactorUser(c, &request)
+		if !found {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		codes, err := helper.RegenerateRecoveryCodes(ctx, user, request.Code)
+		if err != nil {
+			sendTwoFactorError(c, err)
+			return
+		}
+
+		response := helper.SuccessResponse(gin.H{"recovery_codes": codes}, "Recovery codes regenerated")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
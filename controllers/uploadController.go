@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UploadImage accepts an image in the "file" field of a multipart form and stores
+// its variants. The returned url can be used as a menu logo or banner or as an
+// item image.
+func UploadImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxBytes := helper.UploadMaxBytes()
+		// Leave room for the rest of the multipart form.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			response := helper.ErrorResponse(nil, "An image is required in the file field")
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		tooLarge := fmt.Sprintf("Images may be at most %d MB", maxBytes>>20)
+		if header.Size > maxBytes {
+			response := helper.ErrorResponse(nil, tooLarge)
+			response.SendJSON(c.Writer, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(file, maxBytes+1))
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) > maxBytes {
+			response := helper.ErrorResponse(nil, tooLarge)
+			response.SendJSON(c.Writer, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		variants, err := helper.ProcessImage(data)
+		if err == helper.ErrUnsupportedImage {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusUnsupportedMediaType)
+			return
+		}
+		if err == helper.ErrImageTooLarge {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while processing the image")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		storage, err := helper.UploadStorage()
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		id := primitive.NewObjectID().Hex()
+		stored := make([]string, 0, len(variants))
+		urls := make(gin.H, len(variants))
+		var largeURL string
+		for _, variant := range variants {
+			key := "images/" + id + "/" + variant.Name + "." + variant.Extension
+			url, err := storage.Put(ctx, key, variant.ContentType, variant.Data)
+			if err != nil {
+				for _, key := range stored {
+					storage.Delete(ctx, key)
+				}
+				response := helper.ErrorResponse(nil, "Error while storing the image")
+				response.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+			stored = append(stored, key)
+			if variant.Name == "large" {
+				largeURL = url
+			}
+			urls[variant.Name] = gin.H{
+				"url":          url,
+				"width":        variant.Width,
+				"height":       variant.Height,
+				"content_type": variant.ContentType,
+			}
+		}
+
+		responseData := gin.H{
+			"id":       id,
+			"url":      largeURL,
+			"variants": urls,
+		}
+		response := helper.SuccessResponse(responseData, "Image uploaded successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,16 +24,50 @@ import (
 )
 
 var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
-var validate = validator.New()
+var validate = newValidator()
+
+// newValidator returns the validator used for request bodies. A Money field that
+// was left out of the body reads as missing, so that required works on prices.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		money := field.Interface().(models.Money)
+		if money == (models.Money{}) {
+			return nil
+		}
+		return money.String()
+	}, models.Money{})
+	return v
+}
+
+// passwordCost is the bcrypt cost of new password hashes. Existing hashes keep the
+// cost they were made with. A higher cost makes every login and password change
+// tie up a CPU for much longer, which is easy to abuse with parallel requests.
+const passwordCost = 12
 
 func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
 	if err != nil {
 		log.Panic(err)
 	}
 	return string(bytes)
 }
 
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash returns a hash at passwordCost that no password matches. Login
+// checks the password against it when the email is unknown, so that an unknown
+// email takes as long to refuse as a wrong password.
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash = HashPassword(primitive.NewObjectID().Hex())
+	})
+	return dummyHash
+}
+
 func VerifyPassword(userPassword string, providedPassword string) (bool, string) {
 	err := bcrypt.CompareHashAndPassword([]byte(providedPassword), []byte(userPassword))
 	check := true
@@ -93,6 +130,9 @@ func Signup() gin.HandlerFunc {
 		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
+		user.Email_verified = false
+		user.Phone_verified = false
+		user.Totp_enabled = false
 		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, *user.User_type, *&user.User_id)
 		user.Token = &token
 		user.Refresh_token = &refreshToken
@@ -104,6 +144,23 @@ func Signup() gin.HandlerFunc {
 			return
 		}
 
+		if err := helper.StartSession(ctx, user.User_id, refreshToken); err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while starting the session")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		go func(user models.User) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			for _, channel := range []string{helper.VerifyEmail, helper.VerifyPhone} {
+				if err := helper.SendVerification(ctx, user, channel); err != nil {
+					log.Println("verification code could not be sent:", err)
+				}
+			}
+		}(user)
+
 		response := resultInsertionNumber
 		successResponse := helper.SuccessResponse(response, "User successfully created")
 		successResponse.SendJSON(c.Writer, http.StatusOK)
@@ -123,10 +180,25 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		if user.Email == nil || user.Password == nil {
+			errorResponse := helper.ErrorResponse(nil, "email and password are required")
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			cancel()
+			return
+		}
+
+		reservation, blocked := reserveLogin(c, ctx, *user.Email)
+		if blocked {
+			cancel()
+			return
+		}
+
 		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
 		defer cancel()
 		if err != nil {
-			errorResponse := helper.ErrorResponse(nil, "email or password is incorrect")
+			_, msg := VerifyPassword(*user.Password, dummyPasswordHash())
+			recordLoginFailure(ctx, reservation)
+			errorResponse := helper.ErrorResponse(nil, msg)
 			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
 			return
 		}
@@ -134,29 +206,179 @@ func Login() gin.HandlerFunc {
 		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
 		defer cancel()
 		if !passwordIsValid {
+			recordLoginFailure(ctx, reservation)
 			errorResponse := helper.ErrorResponse(nil, msg)
 			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
 			return
 		}
 
 		if foundUser.Email == nil {
+			releaseLogin(ctx, reservation)
 			errorResponse := helper.ErrorResponse(nil, "user not found")
 			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
 			return
 		}
 
-		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, *foundUser.User_type, foundUser.User_id)
-		helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
-		err = userCollection.FindOne(ctx, bson.M{"user_id": foundUser.User_id}).Decode(&foundUser)
+		if foundUser.Totp_enabled {
+			// The account is not logged in until the second factor is checked, so
+			// its failures are kept.
+			releaseLogin(ctx, reservation)
+			challengeToken, err := helper.GenerateChallengeToken(foundUser.User_id)
+			if err != nil {
+				errorResponse := helper.ErrorResponse(nil, err.Error())
+				errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+
+			response := gin.H{
+				"two_factor_required": true,
+				"challenge_token":     challengeToken,
+			}
+			successResponse := helper.SuccessResponse(response, "Enter the code from your authenticator app")
+			successResponse.SendJSON(c.Writer, http.StatusOK)
+			return
+		}
+
+		sendLoginTokens(c, ctx, foundUser, reservation)
+	}
+}
+
+// reserveLogin counts a login attempt for the email from the client's address.
+// It answers with 429 and returns true when logins for either have failed too
+// often recently.
+func reserveLogin(c *gin.Context, ctx context.Context, email string) (*helper.LoginReservation, bool) {
+	reservation, wait, err := helper.ReserveLogin(ctx, email, c.ClientIP())
+	if err != nil {
+		errorResponse := helper.ErrorResponse(nil, err.Error())
+		errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+		return nil, true
+	}
+	if wait <= 0 {
+		return reservation, false
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	errorResponse := helper.TooManyRequestsResponse(nil, fmt.Sprintf("Too many failed logins, try again in %d seconds", seconds))
+	errorResponse.SendJSON(c.Writer, http.StatusTooManyRequests)
+	return nil, true
+}
+
+func recordLoginFailure(ctx context.Context, reservation *helper.LoginReservation) {
+	if err := reservation.Fail(ctx); err != nil {
+		log.Println("failed login could not be recorded:", err)
+	}
+}
+
+func releaseLogin(ctx context.Context, reservation *helper.LoginReservation) {
+	if err := reservation.Release(ctx); err != nil {
+		log.Println("login attempt could not be released:", err)
+	}
+}
+
+// sendLoginTokens starts a new session for the user and answers with the user
+// and its tokens. The failed logins of the account are forgotten.
+func sendLoginTokens(c *gin.Context, ctx context.Context, foundUser models.User, reservation *helper.LoginReservation) {
+	if err := reservation.Succeed(ctx); err != nil {
+		log.Println("failed logins could not be cleared:", err)
+	}
+
+	token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, *foundUser.User_type, foundUser.User_id)
+	helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
+	err := userCollection.FindOne(ctx, bson.M{"user_id": foundUser.User_id}).Decode(&foundUser)
+
+	if err != nil {
+		errorResponse := helper.ErrorResponse(nil, err.Error())
+		errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+		return
+	}
+
+	successResponse := helper.SuccessResponse(foundUser, "")
+	successResponse.SendJSON(c.Writer, http.StatusOK)
+}
+
+// LoginTwoFactor is the second step of Login for users with two-factor
+// authentication. It exchanges the challenge token and a code from the user's app,
+// or a recovery code, for the user's tokens. A challenge token works only once.
+func LoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Challenge_token string `json:"challenge_token" validate:"required"`
+			Code            string `json:"code" validate:"required"`
+		}
+
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
 
+		claims, msg := helper.ValidateChallengeToken(request.Challenge_token)
+		if msg != "" {
+			errorResponse := helper.UnauthorizedResponse(nil, msg)
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := helper.IsTokenRevoked(claims)
 		if err != nil {
 			errorResponse := helper.ErrorResponse(nil, err.Error())
 			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
 			return
 		}
+		if revoked {
+			errorResponse := helper.UnauthorizedResponse(nil, "challenge token has already been used")
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
 
-		successResponse := helper.SuccessResponse(foundUser, "")
-		successResponse.SendJSON(c.Writer, http.StatusOK)
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var foundUser models.User
+		err = userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid}).Decode(&foundUser)
+		if err != nil {
+			errorResponse := helper.UnauthorizedResponse(nil, "user not found")
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		reservation, blocked := reserveLogin(c, ctx, *foundUser.Email)
+		if blocked {
+			return
+		}
+
+		err = helper.VerifySecondFactor(ctx, foundUser, request.Code)
+		if err == helper.ErrInvalidTOTPCode {
+			recordLoginFailure(ctx, reservation)
+		} else if err != nil {
+			releaseLogin(ctx, reservation)
+		}
+		if err == helper.ErrInvalidTOTPCode || err == helper.ErrTOTPNotEnabled {
+			errorResponse := helper.UnauthorizedResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if err := helper.RevokeToken(claims.Id, claims.Uid, claims.ExpiresAt); err != nil {
+			releaseLogin(ctx, reservation)
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		sendLoginTokens(c, ctx, foundUser, reservation)
 	}
 }
 func GetUsers() gin.HandlerFunc {
@@ -241,3 +463,297 @@ func GetUser() gin.HandlerFunc {
 		response.SendJSON(c.Writer, http.StatusOK)
 	}
 }
+
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Refresh_token string `json:"refresh_token" validate:"required"`
+		}
+
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		claims, msg := helper.ValidateRefreshToken(request.Refresh_token)
+		if msg != "" {
+			errorResponse := helper.UnauthorizedResponse(nil, msg)
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := helper.IsTokenRevoked(claims)
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			errorResponse := helper.UnauthorizedResponse(nil, "refresh token has been revoked")
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var foundUser models.User
+		err = userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid}).Decode(&foundUser)
+		if err != nil {
+			errorResponse := helper.UnauthorizedResponse(nil, "user not found")
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		token, refreshToken, _ := helper.GenerateFamilyTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, *foundUser.User_type, foundUser.User_id, claims.Family)
+		rotated, err := helper.RotateTokens(token, refreshToken, request.Refresh_token, foundUser.User_id)
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while refreshing the token")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if !rotated {
+			// The token was valid but is no longer the stored one: it has already been
+			// exchanged, so whoever holds the rest of its family loses the session too.
+			if err := helper.RevokeTokenFamily(foundUser.User_id, claims.Family); err != nil {
+				errorResponse := helper.ErrorResponse(nil, "error occured while revoking the session")
+				errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+				return
+			}
+			errorResponse := helper.UnauthorizedResponse(nil, "refresh token has already been used")
+			errorResponse.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		response := gin.H{
+			"token":         token,
+			"refresh_token": refreshToken,
+		}
+		successResponse := helper.SuccessResponse(response, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("uid")
+
+		if err := helper.RevokeToken(c.GetString("jti"), userID, c.GetInt64("expires_at")); err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while logging out")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		// Only the session of this access token ends; tokens issued before sessions
+		// were kept apart carry no family and end every session.
+		var err error
+		if family := c.GetString("family"); family != "" {
+			err = helper.RevokeTokenFamily(userID, family)
+		} else {
+			err = helper.ClearTokens(userID)
+		}
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while logging out")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(nil, "Logged out successfully")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func LogoutAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helper.RevokeAllTokens(c.GetString("uid")); err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while logging out")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(nil, "Logged out from all devices")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// ForgotPassword mails a reset link to the account with the given email. The
+// answer is the same whether or not the email is registered, and the mail is sent
+// in the background so the response time does not tell either.
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		go func(email string) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			var foundUser models.User
+			if err := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&foundUser); err != nil {
+				if err != mongo.ErrNoDocuments {
+					log.Println("password reset lookup failed:", err)
+				}
+				return
+			}
+
+			token, err := helper.CreatePasswordReset(ctx, foundUser.User_id)
+			if err != nil {
+				log.Println("password reset could not be created:", err)
+				return
+			}
+			if err := helper.SendPasswordReset(ctx, email, token); err != nil {
+				log.Println("password reset mail failed:", err)
+			}
+		}(request.Email)
+
+		successResponse := helper.SuccessResponse(nil, "If the email is registered, a reset link has been sent to it")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and signs
+// the user out everywhere.
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Token    string `json:"token" validate:"required"`
+			Password string `json:"password" validate:"required,min=6"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, err := helper.ResetPassword(ctx, request.Token, HashPassword(request.Password))
+		if err == helper.ErrInvalidResetToken {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while resetting the password")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		if err := helper.RevokeAllTokens(userID); err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while signing out the old sessions")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(nil, "Password has been reset")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// UnlockAccount lets an admin lift the lockout of an account after failed logins.
+func UnlockAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helper.UnlockAccount(ctx, request.Email, c.GetString("uid"), c.ClientIP()); err != nil {
+			errorResponse := helper.ErrorResponse(nil, "error occured while unlocking the account")
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(nil, "Account unlocked")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// GetAuditLogs lets an admin read the latest lockout and unlock events.
+func GetAuditLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Email string `json:"email" validate:"omitempty,email"`
+			Limit int64  `json:"limit" validate:"omitempty,min=1,max=500"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			errorResponse := helper.ErrorResponse(nil, validationErr.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if request.Limit == 0 {
+			request.Limit = 100
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		logs, err := helper.ListAuditLogs(ctx, request.Email, request.Limit)
+		if err != nil {
+			errorResponse := helper.ErrorResponse(nil, err.Error())
+			errorResponse.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(logs, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
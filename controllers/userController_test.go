@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/middleware"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if helper.SECRET_KEY == "" {
+		helper.SECRET_KEY = "test"
+	}
+	os.Exit(m.Run())
+}
+
+// requireDatabase skips the test unless MONGODB_DATABASE names a scratch database
+// on a reachable MongoDB. The tests write to that database.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv("MONGODB_DATABASE") == "" {
+		t.Skip("set MONGODB_DATABASE to a scratch database to run the database tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := database.Client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB is not reachable: %v", err)
+	}
+}
+
+// seedUser stores a user and removes it when the test ends.
+func seedUser(t *testing.T, user models.User) models.User {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	user.Created_at = time.Now()
+	user.Updated_at = time.Now()
+	if user.Email == nil {
+		email := user.User_id + "@example.com"
+		user.Email = &email
+	}
+
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		userCollection.DeleteOne(context.Background(), bson.M{"_id": user.ID})
+	})
+	return user
+}
+
+// accessToken returns an access token for the user.
+func accessToken(t *testing.T, user models.User) string {
+	t.Helper()
+	userType := "USER"
+	if user.User_type != nil {
+		userType = *user.User_type
+	}
+	token, _, err := helper.GenerateAllTokens(*user.Email, "Test", "User", userType, user.User_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// postJSON posts body to handler. With a token the request is authenticated
+// first, as on the routes that require signing in.
+func postJSON(handler gin.HandlerFunc, body interface{}, token string) *httptest.ResponseRecorder {
+	router := gin.New()
+	if token != "" {
+		router.POST("/", middleware.Authenticate(), handler)
+	} else {
+		router.POST("/", handler)
+	}
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req := httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Token", token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestForgotPasswordAnswersAlikeForUnknownEmails(t *testing.T) {
+	requireDatabase(t)
+
+	user := seedUser(t, models.User{})
+	resets := database.OpenCollection(database.Client, "password-reset")
+	defer resets.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	registered := postJSON(ForgotPassword(), gin.H{"email": *user.Email}, "")
+	unknown := postJSON(ForgotPassword(), gin.H{"email": primitive.NewObjectID().Hex() + "@example.com"}, "")
+
+	if registered.Code != http.StatusOK || unknown.Code != http.StatusOK {
+		t.Fatalf("status = %d and %d, want %d", registered.Code, unknown.Code, http.StatusOK)
+	}
+	if registered.Body.String() != unknown.Body.String() {
+		t.Errorf("responses differ:\n%s\n%s", registered.Body, unknown.Body)
+	}
+
+	// The reset is still made for the registered email, after the response.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		count, err := resets.CountDocuments(context.Background(), bson.M{"userid": user.User_id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no password reset was created for the registered email")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSignupTokensCanBeRefreshed(t *testing.T) {
+	requireDatabase(t)
+
+	email := primitive.NewObjectID().Hex() + "@example.com"
+	signup := gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"Password":   "secret123",
+		"email":      email,
+		"phone":      primitive.NewObjectID().Hex(),
+		"user_type":  "USER",
+	}
+	w := postJSON(Signup(), signup, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("signup: status = %d: %s", w.Code, w.Body)
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(context.Background(), bson.M{"email": email}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	defer userCollection.DeleteOne(context.Background(), bson.M{"_id": user.ID})
+	defer database.OpenCollection(database.Client, "session").DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	w = postJSON(RefreshToken(), gin.H{"refresh_token": *user.Refresh_token}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status = %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Data struct {
+			Refresh_token string `json:"refresh_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := userCollection.FindOne(context.Background(), bson.M{"_id": user.ID}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Refresh_token == nil || *user.Refresh_token != response.Data.Refresh_token {
+		t.Error("the user does not keep the rotated refresh token")
+	}
+}
+
+func TestDummyPasswordHashCostsAsMuchAsARealOne(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != passwordCost {
+		t.Errorf("cost = %d, want %d", cost, passwordCost)
+	}
+	if ok, _ := VerifyPassword("", dummyPasswordHash()); ok {
+		t.Error("the dummy hash matched an empty password")
+	}
+}
+
+func TestLoginAnswersAlikeForUnknownEmails(t *testing.T) {
+	requireDatabase(t)
+
+	password := HashPassword("correct horse")
+	user := seedUser(t, models.User{Password: &password, Email_verified: true})
+	attempts := database.OpenCollection(database.Client, "login-attempt")
+	unknownEmail := primitive.NewObjectID().Hex() + "@example.com"
+	defer attempts.DeleteMany(context.Background(), bson.M{"key": bson.M{"$in": []string{"account:" + *user.Email, "account:" + unknownEmail, "ip:192.0.2.1"}}})
+
+	wrong := postJSON(Login(), gin.H{"email": *user.Email, "password": "wrong"}, "")
+	unknown := postJSON(Login(), gin.H{"email": unknownEmail, "password": "wrong"}, "")
+
+	if wrong.Code != http.StatusUnauthorized || unknown.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d and %d, want %d", wrong.Code, unknown.Code, http.StatusUnauthorized)
+	}
+	if wrong.Body.String() != unknown.Body.String() {
+		t.Errorf("responses differ:\n%s\n%s", wrong.Body, unknown.Body)
+	}
+}
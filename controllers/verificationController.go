@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// resendVerification sends the signed in user a new code on the channel, at most
+// once per cooldown.
+func resendVerification(channel string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := useContext()
+		defer cancel()
+
+		userID := c.GetString("uid")
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+			response := helper.UnauthorizedResponse(nil, "user not found")
+			response.SendJSON(c.Writer, http.StatusUnauthorized)
+			return
+		}
+
+		wait, err := helper.VerificationWait(ctx, userID, channel)
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+		if wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			response := helper.TooManyRequestsResponse(nil, fmt.Sprintf("Please wait %d seconds before requesting a new code", seconds))
+			response.SendJSON(c.Writer, http.StatusTooManyRequests)
+			return
+		}
+
+		err = helper.SendVerification(ctx, user, channel)
+		if err == helper.ErrAlreadyVerified {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while sending the verification code")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(nil, "Verification code sent")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// confirmVerification marks the channel of the signed in user as verified when
+// the code matches.
+func confirmVerification(channel string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Code string `json:"code" validate:"required,len=6,numeric"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			response := helper.ErrorResponse(nil, validationErr.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		err := helper.ConfirmVerification(ctx, c.GetString("uid"), channel, request.Code)
+		if err == helper.ErrInvalidVerificationCode {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while verifying the code")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		response := helper.SuccessResponse(nil, "Verified successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func ResendEmailVerification() gin.HandlerFunc {
+	return resendVerification(helper.VerifyEmail)
+}
+
+func ResendPhoneVerification() gin.HandlerFunc {
+	return resendVerification(helper.VerifyPhone)
+}
+
+func VerifyEmail() gin.HandlerFunc {
+	return confirmVerification(helper.VerifyEmail)
+}
+
+func VerifyPhone() gin.HandlerFunc {
+	return confirmVerification(helper.VerifyPhone)
+}
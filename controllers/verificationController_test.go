@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func setEnv(t *testing.T, name string, value string) {
+	t.Helper()
+	old, set := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestResendVerificationWaitsForTheCooldown(t *testing.T) {
+	requireDatabase(t)
+	setEnv(t, "VERIFICATION_RESEND_SECONDS", "60")
+
+	user := seedUser(t, models.User{})
+	verifications := database.OpenCollection(database.Client, "verification")
+	defer verifications.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+	token := accessToken(t, user)
+
+	if w := postJSON(ResendEmailVerification(), gin.H{}, token); w.Code != http.StatusOK {
+		t.Fatalf("first code: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	w := postJSON(ResendEmailVerification(), gin.H{}, token)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second code: status = %d, want %d: %s", w.Code, http.StatusTooManyRequests, w.Body)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Retry-After = %q", retryAfter)
+	}
+}
+
+func TestAddUpdateMenuRequiresVerifiedEmail(t *testing.T) {
+	requireDatabase(t)
+	menu := gin.H{"name": "Menu", "logo": "logo.png", "banner": "banner.png"}
+
+	tests := []struct {
+		name     string
+		required string
+		verified bool
+		want     int
+	}{
+		{"gate off", "false", false, http.StatusOK},
+		{"unverified", "true", false, http.StatusForbidden},
+		{"verified", "true", true, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, "REQUIRE_EMAIL_VERIFICATION", test.required)
+			user := seedUser(t, models.User{Email_verified: test.verified})
+			defer menuCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+			w := postJSON(AddUpdateMenu(), menu, accessToken(t, user))
+			if w.Code != test.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.want, w.Body)
+			}
+
+			count, err := menuCollection.CountDocuments(context.Background(), bson.M{"userid": user.User_id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created := count == 1; created != (test.want == http.StatusOK) {
+				t.Errorf("menus created = %d", count)
+			}
+		})
+	}
+}
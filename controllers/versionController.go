@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// bindVersionRequest reads and validates the body and checks that the user owns
+// the menu named by menuID. It sends the error response itself and returns false
+// on failure.
+func bindVersionRequest(c *gin.Context, request interface{}, menuID *primitive.ObjectID) bool {
+	if err := c.BindJSON(request); err != nil {
+		response := helper.ErrorResponse(nil, err.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+		return false
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		response := helper.ErrorResponse(nil, validationErr.Error())
+		response.SendJSON(c.Writer, http.StatusBadRequest)
+		return false
+	}
+
+	return ownershipGranted(c, helper.CheckMenuOwner(c, menuID.Hex()))
+}
+
+func PublishMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID primitive.ObjectID `json:"menu_id"`
+			Note   *string            `json:"note" validate:"omitempty,max=200"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		version, err := helper.PublishMenu(ctx, request.MenuID, c.GetString("uid"), request.Note)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Menu not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while publishing the menu")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishLiveMenuEvent(ctx, "menu", "published", request.MenuID, gin.H{"version": version.Version})
+
+		version.Menu = models.Menu{}
+		response := helper.SuccessResponse(version, "Menu published successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func GetMenuVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID primitive.ObjectID `json:"menu_id"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		versions, err := helper.ListMenuVersions(ctx, request.MenuID.Hex())
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		successResponse := helper.SuccessResponse(versions, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+// DiffMenuVersions compares version From with version To, or with the current
+// draft if To is zero.
+func DiffMenuVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID primitive.ObjectID `json:"menu_id"`
+			From   int                `json:"from" validate:"gte=1"`
+			To     int                `json:"to" validate:"gte=0"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		from, err := helper.FindMenuVersion(ctx, request.MenuID.Hex(), request.From)
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Version not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		var to models.Menu
+		if request.To == 0 {
+			to, err = helper.LoadMenuTree(ctx, request.MenuID)
+		} else {
+			var version models.MenuVersion
+			version, err = helper.FindMenuVersion(ctx, request.MenuID.Hex(), request.To)
+			to = version.Menu
+		}
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Version not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, err.Error())
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		responseData := gin.H{
+			"from":    request.From,
+			"to":      request.To,
+			"changes": helper.DiffMenus(from.Menu, to),
+		}
+		successResponse := helper.SuccessResponse(responseData, "")
+		successResponse.SendJSON(c.Writer, http.StatusOK)
+	}
+}
+
+func RollbackMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MenuID  primitive.ObjectID `json:"menu_id"`
+			Version int                `json:"version" validate:"gte=1"`
+		}
+		if !bindVersionRequest(c, &request, &request.MenuID) {
+			return
+		}
+
+		ctx, cancel := useContext()
+		defer cancel()
+
+		version, err := helper.RollbackMenu(ctx, request.MenuID, request.Version, c.GetString("uid"))
+		if err == mongo.ErrNoDocuments {
+			response := helper.NotFoundResponse(nil, "Version not found")
+			response.SendJSON(c.Writer, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response := helper.ErrorResponse(nil, "Error while rolling back the menu")
+			response.SendJSON(c.Writer, http.StatusInternalServerError)
+			return
+		}
+
+		helper.PublishLiveMenuEvent(ctx, "menu", "published", request.MenuID, gin.H{"version": version.Version})
+
+		version.Menu = models.Menu{}
+		response := helper.SuccessResponse(version, "Menu rolled back successfully")
+		response.SendJSON(c.Writer, http.StatusOK)
+	}
+}
@@ -12,13 +12,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const DefaultDatabase = "qr-menu"
+
 func DBinstance() *mongo.Client {
+	// The .env file is optional so that tests and containers can configure the
+	// connection through the environment alone.
 	err := godotenv.Load(".env")
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		log.Fatal("Error loading .env file")
 	}
 
 	MongoDb := os.Getenv("MONGODB_URL")
+	if MongoDb == "" {
+		MongoDb = "mongodb://localhost:27017/"
+	}
 
 	client, err := mongo.NewClient(options.Client().ApplyURI(MongoDb))
 	if err != nil {
@@ -38,7 +45,16 @@ func DBinstance() *mongo.Client {
 
 var Client *mongo.Client = DBinstance()
 
+// DatabaseName is the database every collection is opened in, MONGODB_DATABASE
+// or qr-menu if that is not set.
+func DatabaseName() string {
+	if name := os.Getenv("MONGODB_DATABASE"); name != "" {
+		return name
+	}
+	return DefaultDatabase
+}
+
 func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("qr-menu").Collection(collectionName)
+	var collection *mongo.Collection = client.Database(DatabaseName()).Collection(collectionName)
 	return collection
 }
@@ -8,6 +8,10 @@ require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/go-playground/validator/v10 v10.15.5
 	github.com/joho/godotenv v1.3.0
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	go.mongodb.org/mongo-driver v1.7.2
 	golang.org/x/crypto v0.14.0
+	golang.org/x/image v0.13.0
+	golang.org/x/text v0.13.0
 )
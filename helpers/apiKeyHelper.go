@@ -0,0 +1,31 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// APIKeyID tells whether key is one of the keys listed in API_KEYS, separated by
+// commas, and returns a name for it that is safe to store: the start of its
+// SHA-256 hash. Unknown keys are refused, so a client cannot pick a fresh rate
+// limit bucket by sending a made up key.
+func APIKeyID(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	valid := false
+	for _, known := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		known = strings.TrimSpace(known)
+		if known != "" && subtle.ConstantTimeCompare([]byte(known), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	if !valid {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8]), true
+}
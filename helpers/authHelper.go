@@ -1,9 +1,16 @@
 package helper
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func CheckUserType(c *gin.Context, role string) (err error) {
@@ -28,3 +35,111 @@ func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
 	err = CheckUserType(c, userType)
 	return err
 }
+
+var ErrForbidden = errors.New("Unauthorized to access this resource")
+var ErrNotFound = errors.New("Resource not found")
+
+var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
+var menuGroupCollection *mongo.Collection = database.OpenCollection(database.Client, "menu-group")
+var menuItemCollection *mongo.Collection = database.OpenCollection(database.Client, "menu-item")
+
+// CheckMenuOwner allows the request if the menu belongs to the authenticated user
+// or the user is an ADMIN. The menu itself may be in the trash.
+func CheckMenuOwner(c *gin.Context, menuID string) error {
+	return checkMenuOwner(c, menuID, false)
+}
+
+// CheckParentMenuOwner checks the owner of a menu that records are added to or
+// arranged in. A menu in the trash is refused with ErrParentDeleted.
+func CheckParentMenuOwner(c *gin.Context, menuID string) error {
+	return checkMenuOwner(c, menuID, true)
+}
+
+func checkMenuOwner(c *gin.Context, menuID string, parent bool) error {
+	id, err := primitive.ObjectIDFromHex(menuID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var menu models.Menu
+	err = menuCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.GetString("user_type") != "ADMIN" && (menu.UserID == nil || *menu.UserID != c.GetString("uid")) {
+		return ErrForbidden
+	}
+	if parent && menu.DeletedAt != nil {
+		return ErrParentDeleted
+	}
+	return nil
+}
+
+// CheckGroupOwner resolves the menu of a group and checks its owner. The group
+// may be in the trash, but not its menu.
+func CheckGroupOwner(c *gin.Context, groupID string) error {
+	return checkGroupOwner(c, groupID, false)
+}
+
+// CheckParentGroupOwner checks the owner of a group that items are added to or
+// arranged in. A group or menu in the trash is refused with ErrParentDeleted.
+func CheckParentGroupOwner(c *gin.Context, groupID string) error {
+	return checkGroupOwner(c, groupID, true)
+}
+
+func checkGroupOwner(c *gin.Context, groupID string, parent bool) error {
+	id, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var group models.MenuGroup
+	err = menuGroupCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&group)
+	if err == mongo.ErrNoDocuments || (err == nil && group.MenuID == nil) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := checkMenuOwner(c, *group.MenuID, true); err != nil {
+		return err
+	}
+	if parent && group.DeletedAt != nil {
+		return ErrParentDeleted
+	}
+	return nil
+}
+
+// CheckItemOwner resolves item → group → menu and checks the menu owner. The item
+// may be in the trash, but not its group or menu.
+func CheckItemOwner(c *gin.Context, itemID string) error {
+	id, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var item models.MenuItem
+	err = menuItemCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if err == mongo.ErrNoDocuments || (err == nil && item.GroupID == nil) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return checkGroupOwner(c, *item.GroupID, true)
+}
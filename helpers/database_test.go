@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// requireDatabase skips the test unless MONGODB_DATABASE names a scratch database
+// on a reachable MongoDB. The tests write to that database.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv("MONGODB_DATABASE") == "" {
+		t.Skip("set MONGODB_DATABASE to a scratch database to run the database tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := database.Client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB is not reachable: %v", err)
+	}
+}
+
+// seedUser stores a user and removes it when the test ends.
+func seedUser(t *testing.T, user models.User) models.User {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	user.Created_at = time.Now()
+	user.Updated_at = time.Now()
+	if user.Email == nil {
+		email := user.User_id + "@example.com"
+		user.Email = &email
+	}
+
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		userCollection.DeleteOne(context.Background(), bson.M{"_id": user.ID})
+	})
+	return user
+}
@@ -0,0 +1,176 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// eventHistory is the number of recent events kept for clients that reconnect
+// with a Last-Event-ID.
+const eventHistory = 1000
+
+// subscriberBuffer is the number of events a slow client may fall behind before
+// it is disconnected and has to resume.
+const subscriberBuffer = 64
+
+type MenuEvent struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	MenuID   string      `json:"menu_id"`
+	TargetID string      `json:"target_id"`
+	OwnerID  string      `json:"-"`
+	Private  bool        `json:"-"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+type eventSubscriber struct {
+	events chan MenuEvent
+	filter func(MenuEvent) bool
+}
+
+type eventBroker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []MenuEvent
+	subscribers map[*eventSubscriber]bool
+}
+
+// Event ids start at the boot time in milliseconds, so ids from before a restart
+// are older than anything this process has sent and force a resync.
+var menuEvents = &eventBroker{
+	nextID:      uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+	subscribers: make(map[*eventSubscriber]bool),
+}
+
+func (b *eventBroker) publish(event MenuEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.ID = b.nextID
+	b.nextID++
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistory {
+		b.history = b.history[len(b.history)-eventHistory:]
+	}
+
+	for subscriber := range b.subscribers {
+		if !subscriber.filter(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			delete(b.subscribers, subscriber)
+			close(subscriber.events)
+		}
+	}
+}
+
+func (b *eventBroker) subscribe(filter func(MenuEvent) bool, lastEventID uint64) (*eventSubscriber, []MenuEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriber := &eventSubscriber{events: make(chan MenuEvent, subscriberBuffer), filter: filter}
+	b.subscribers[subscriber] = true
+
+	if lastEventID == 0 {
+		return subscriber, nil, true
+	}
+
+	oldest := b.nextID
+	if len(b.history) > 0 {
+		oldest = b.history[0].ID
+	}
+	if lastEventID+1 < oldest {
+		return subscriber, nil, false
+	}
+
+	missed := make([]MenuEvent, 0)
+	for _, event := range b.history {
+		if event.ID > lastEventID && filter(event) {
+			missed = append(missed, event)
+		}
+	}
+	return subscriber, missed, true
+}
+
+func (b *eventBroker) unsubscribe(subscriber *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[subscriber] {
+		delete(b.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// PublishMenuEvent announces that a menu, group or item of the draft was created,
+// updated or deleted. The event type is the kind and the action joined by a dot,
+// for example "item.updated". Draft changes are only sent to the owner.
+func PublishMenuEvent(ctx context.Context, kind string, action string, id primitive.ObjectID, data interface{}) {
+	publishMenuEvent(ctx, kind, action, id, data, true)
+}
+
+// PublishLiveMenuEvent announces a change that guests see right away, such as a
+// new published version or an item selling out.
+func PublishLiveMenuEvent(ctx context.Context, kind string, action string, id primitive.ObjectID, data interface{}) {
+	publishMenuEvent(ctx, kind, action, id, data, false)
+}
+
+func publishMenuEvent(ctx context.Context, kind string, action string, id primitive.ObjectID, data interface{}, private bool) {
+	menu, err := MenuOf(ctx, kind, id)
+	if err != nil {
+		return
+	}
+
+	event := MenuEvent{
+		Type:     kind + "." + action,
+		MenuID:   menu.ID.Hex(),
+		TargetID: id.Hex(),
+		Private:  private,
+		Data:     data,
+	}
+	if menu.UserID != nil {
+		event.OwnerID = *menu.UserID
+	}
+	menuEvents.publish(event)
+}
+
+// PublishServiceEvent announces a change to a waiter call to the owner of its menu.
+// Service events are never sent on the public menu channel.
+func PublishServiceEvent(ctx context.Context, action string, request models.ServiceRequest) {
+	menuID, err := primitive.ObjectIDFromHex(request.MenuID)
+	if err != nil {
+		return
+	}
+	menu, err := MenuOf(ctx, "menu", menuID)
+	if err != nil {
+		return
+	}
+
+	event := MenuEvent{
+		Type:     "service." + action,
+		MenuID:   request.MenuID,
+		TargetID: request.ID.Hex(),
+		Private:  true,
+		Data:     request,
+	}
+	if menu.UserID != nil {
+		event.OwnerID = *menu.UserID
+	}
+	menuEvents.publish(event)
+}
+
+// SubscribeMenuEvents registers a listener for the events that pass filter. It
+// returns the events after lastEventID that the listener missed; resumed is false
+// if some of them are no longer kept and the client has to reload instead. The
+// channel is closed when cancel is called or the listener falls too far behind.
+func SubscribeMenuEvents(filter func(MenuEvent) bool, lastEventID uint64) (events <-chan MenuEvent, missed []MenuEvent, resumed bool, cancel func()) {
+	subscriber, missed, resumed := menuEvents.subscribe(filter, lastEventID)
+	return subscriber.events, missed, resumed, func() { menuEvents.unsubscribe(subscriber) }
+}
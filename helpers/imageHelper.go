@@ -0,0 +1,255 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxImagePixels rejects images whose decoded size would use too much memory,
+// however small the uploaded file is.
+const maxImagePixels = 40000000
+
+var ErrUnsupportedImage = errors.New("Only JPEG, PNG, GIF and WebP images are accepted")
+
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type imageSize struct {
+	name    string
+	maxSide int
+}
+
+// imageSizes are the responsive variants made of every upload. The WebP variant
+// has the size of the large one.
+var imageSizes = []imageSize{
+	{"thumbnail", 160},
+	{"medium", 640},
+	{"large", 1280},
+}
+
+type ImageVariant struct {
+	Name        string `json:"name"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"content_type"`
+	Extension   string `json:"-"`
+	Data        []byte `json:"-"`
+}
+
+// UploadMaxBytes returns the largest accepted upload, based on UPLOAD_MAX_MB.
+func UploadMaxBytes() int64 {
+	mb, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_MB"))
+	if err != nil || mb < 1 {
+		mb = 5
+	}
+	return int64(mb) << 20
+}
+
+// ProcessImage checks an uploaded image and returns its variants. The image is
+// decoded and encoded again, so EXIF and all other metadata are dropped; the EXIF
+// orientation of JPEG photos is applied to the pixels first.
+func ProcessImage(data []byte) ([]ImageVariant, error) {
+	if !allowedImageTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	src = orient(src, jpegOrientation(data))
+	opaque := src.Opaque()
+
+	variants := make([]ImageVariant, 0, len(imageSizes)+1)
+	var large *image.NRGBA
+	for _, size := range imageSizes {
+		img := fitImage(src, size.maxSide)
+		large = img
+
+		variant := ImageVariant{Name: size.name, Width: img.Rect.Dx(), Height: img.Rect.Dy()}
+		var buf bytes.Buffer
+		if opaque {
+			variant.ContentType, variant.Extension = "image/jpeg", "jpg"
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		} else {
+			variant.ContentType, variant.Extension = "image/png", "png"
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant.Data = buf.Bytes()
+		variants = append(variants, variant)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, large); err != nil {
+		return nil, err
+	}
+	variants = append(variants, ImageVariant{
+		Name:        "webp",
+		Width:       large.Rect.Dx(),
+		Height:      large.Rect.Dy(),
+		ContentType: "image/webp",
+		Extension:   "webp",
+		Data:        buf.Bytes(),
+	})
+	return variants, nil
+}
+
+// fitImage scales img down so that its longer side is at most maxSide. Smaller
+// images are returned unchanged.
+func fitImage(img *image.NRGBA, maxSide int) *image.NRGBA {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+	if width >= height {
+		height = height * maxSide / width
+		width = maxSide
+	} else {
+		width = width * maxSide / height
+		height = maxSide
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Rect, img, img.Rect, xdraw.Src, nil)
+	return dst
+}
+
+// orient turns the pixels the way the EXIF orientation tag says they should be
+// shown.
+func orient(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.SetNRGBA(x, y, img.NRGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG file, or 1 if it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		if marker == 0xe1 {
+			if orientation := exifOrientation(data[i+4 : i+2+size]); orientation != 0 {
+				return orientation
+			}
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(segment []byte) int {
+	if len(segment) < 14 || string(segment[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := segment[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
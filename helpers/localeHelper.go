@@ -0,0 +1,189 @@
+package helper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used for menus that were created before they had a default locale.
+const DefaultLocale = "tr"
+
+var ErrDefaultLocale = errors.New("The default locale is edited through the regular fields")
+
+// MenuLocales returns the locales a menu is offered in, default locale first.
+func MenuLocales(menu models.Menu) []string {
+	defaultLocale := menu.DefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = DefaultLocale
+	}
+
+	locales := []string{defaultLocale}
+	for _, locale := range menu.Locales {
+		if locale != defaultLocale {
+			locales = append(locales, locale)
+		}
+	}
+	return locales
+}
+
+// NegotiateLocale picks one of the supported locales, preferring an explicit lang
+// parameter over the Accept-Language header. It falls back to supported[0].
+func NegotiateLocale(lang string, acceptLanguage string, supported []string) string {
+	tags := make([]language.Tag, 0, len(supported))
+	names := make([]string, 0, len(supported))
+	for _, locale := range supported {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			continue
+		}
+		tags = append(tags, tag)
+		names = append(names, locale)
+	}
+	if len(tags) == 0 {
+		return DefaultLocale
+	}
+	matcher := language.NewMatcher(tags)
+
+	if lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			if _, index, confidence := matcher.Match(tag); confidence != language.No {
+				return names[index]
+			}
+		}
+	}
+
+	if accepted, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil && len(accepted) > 0 {
+		if _, index, confidence := matcher.Match(accepted...); confidence != language.No {
+			return names[index]
+		}
+	}
+
+	return names[0]
+}
+
+// Localize returns the translated value for locale, or fallback if there is none.
+func Localize(fallback *string, translations map[string]models.Translation, locale string, description bool) *string {
+	translation, ok := translations[locale]
+	if !ok {
+		return fallback
+	}
+
+	value := translation.Name
+	if description {
+		value = translation.Description
+	}
+	if value == nil || *value == "" {
+		return fallback
+	}
+	return value
+}
+
+func translatable(kind string) (*mongo.Collection, bool) {
+	switch kind {
+	case "menu":
+		return menuCollection, true
+	case "group":
+		return menuGroupCollection, true
+	case "item":
+		return menuItemCollection, true
+	}
+	return nil, false
+}
+
+// MenuOf resolves the menu that a menu, group or item belongs to.
+func MenuOf(ctx context.Context, kind string, id primitive.ObjectID) (models.Menu, error) {
+	var menu models.Menu
+	menuID := id
+
+	if kind == "item" {
+		var item models.MenuItem
+		if err := menuItemCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&item); err != nil {
+			return menu, err
+		}
+		if item.GroupID == nil {
+			return menu, mongo.ErrNoDocuments
+		}
+		groupID, err := primitive.ObjectIDFromHex(*item.GroupID)
+		if err != nil {
+			return menu, mongo.ErrNoDocuments
+		}
+		id, kind = groupID, "group"
+	}
+
+	if kind == "group" {
+		var group models.MenuGroup
+		if err := menuGroupCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&group); err != nil {
+			return menu, err
+		}
+		if group.MenuID == nil {
+			return menu, mongo.ErrNoDocuments
+		}
+		var err error
+		if menuID, err = primitive.ObjectIDFromHex(*group.MenuID); err != nil {
+			return menu, mongo.ErrNoDocuments
+		}
+	}
+
+	err := menuCollection.FindOne(ctx, bson.M{"_id": menuID}).Decode(&menu)
+	return menu, err
+}
+
+// SetTranslation stores the translation of a menu, group or item and adds the
+// locale to the menu's locales.
+func SetTranslation(ctx context.Context, kind string, id primitive.ObjectID, locale string, translation models.Translation) error {
+	collection, ok := translatable(kind)
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	menu, err := MenuOf(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if locale == MenuLocales(menu)[0] {
+		return ErrDefaultLocale
+	}
+
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := collection.UpdateOne(sc,
+			bson.M{"_id": id, "deleted_at": nil},
+			bson.M{"$set": bson.M{"translations." + locale: translation}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		_, err = menuCollection.UpdateOne(sc,
+			bson.M{"_id": menu.ID},
+			bson.M{"$addToSet": bson.M{"locales": locale}})
+		return err
+	})
+}
+
+// DeleteTranslation removes the translation of a menu, group or item. The locale
+// stays on the menu; entries without a translation fall back to the default locale.
+func DeleteTranslation(ctx context.Context, kind string, id primitive.ObjectID, locale string) error {
+	collection, ok := translatable(kind)
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": nil},
+		bson.M{"$unset": bson.M{"translations." + locale: ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/sencerarslan/go-app/models"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	supported := []string{"tr", "en", "de"}
+
+	cases := []struct {
+		name           string
+		lang           string
+		acceptLanguage string
+		want           string
+	}{
+		{"nothing asked for", "", "", "tr"},
+		{"lang parameter", "de", "en", "de"},
+		{"lang parameter with region", "en-GB", "", "en"},
+		{"unsupported lang falls back to the header", "fr", "de", "de"},
+		{"header order", "", "en, de", "en"},
+		{"header q-values", "", "en;q=0.3, de;q=0.8", "de"},
+		{"header region", "", "de-AT;q=0.9, fr;q=1", "de"},
+		{"unsupported header", "", "fr, ja;q=0.5", "tr"},
+		{"zero q-value is refused", "", "en;q=0", "tr"},
+		{"malformed header", "", ";;q=x", "tr"},
+	}
+	for _, tc := range cases {
+		if got := NegotiateLocale(tc.lang, tc.acceptLanguage, supported); got != tc.want {
+			t.Errorf("%s: NegotiateLocale(%q, %q) = %q, want %q", tc.name, tc.lang, tc.acceptLanguage, got, tc.want)
+		}
+	}
+
+	if got := NegotiateLocale("en", "", nil); got != DefaultLocale {
+		t.Errorf("without supported locales: got %q, want %q", got, DefaultLocale)
+	}
+}
+
+func TestMenuLocalesPutsTheDefaultFirst(t *testing.T) {
+	menu := models.Menu{DefaultLocale: "en", Locales: []string{"de", "en", "tr"}}
+	got := MenuLocales(menu)
+	want := []string{"en", "de", "tr"}
+	if len(got) != len(want) {
+		t.Fatalf("MenuLocales = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("MenuLocales = %v, want %v", got, want)
+		}
+	}
+
+	if got := MenuLocales(models.Menu{}); len(got) != 1 || got[0] != DefaultLocale {
+		t.Errorf("menu without a default locale: %v, want [%s]", got, DefaultLocale)
+	}
+}
+
+func TestLocalizeFallsBackToTheDefault(t *testing.T) {
+	name, description, english := "Çay", "Demlik", "Tea"
+	translations := map[string]models.Translation{
+		"en": {Name: &english},
+		"de": {Name: new(string)},
+	}
+
+	if got := Localize(&name, translations, "en", false); *got != english {
+		t.Errorf("en name = %q, want %q", *got, english)
+	}
+	if got := Localize(&description, translations, "en", true); *got != description {
+		t.Errorf("missing en description = %q, want %q", *got, description)
+	}
+	if got := Localize(&name, translations, "de", false); *got != name {
+		t.Errorf("empty de name = %q, want %q", *got, name)
+	}
+	if got := Localize(&name, translations, "fr", false); *got != name {
+		t.Errorf("fr name = %q, want %q", *got, name)
+	}
+}
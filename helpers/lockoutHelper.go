@@ -0,0 +1,287 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	AuditAccountLocked   = "account.locked"
+	AuditAccountUnlocked = "account.unlocked"
+	AuditAddressLocked   = "address.locked"
+)
+
+// loginLimit describes how failed logins of one account or one address are
+// slowed down. After free failures every further one doubles the wait, starting
+// at one second; at lockAfter failures the key is locked for the lockout time.
+type loginLimit struct {
+	free      int
+	lockAfter int
+	event     string
+}
+
+var (
+	accountLoginLimit = loginLimit{free: 5, lockAfter: 10, event: AuditAccountLocked}
+	// An address is shared by everyone behind the same NAT, so it gets more room.
+	addressLoginLimit = loginLimit{free: 20, lockAfter: 50, event: AuditAddressLocked}
+)
+
+// loginFailureMemory is how long failures are remembered after the last one.
+const loginFailureMemory = 24 * time.Hour
+
+var loginAttemptCollection *mongo.Collection = database.OpenCollection(database.Client, "login-attempt")
+var auditLogCollection *mongo.Collection = database.OpenCollection(database.Client, "audit-log")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := loginAttemptCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.M{"key": 1},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		log.Println("could not create login-attempt indexes:", err)
+	}
+
+	_, err = auditLogCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "createdat", Value: -1}}},
+		{Keys: bson.M{"createdat": -1}},
+	})
+	if err != nil {
+		log.Println("could not create audit-log indexes:", err)
+	}
+}
+
+// LoginLockout returns how long a locked account or address stays locked, based
+// on LOGIN_LOCKOUT_MINUTES.
+func LoginLockout() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES"))
+	if err != nil || minutes < 1 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func addressKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginReservation is a login attempt that has been counted before the password
+// is checked. Its outcome must be reported with Fail, Succeed or Release.
+type LoginReservation struct {
+	email   string
+	ip      string
+	account int
+	address int
+}
+
+// ReserveLogin counts a login attempt for the email from the address before the
+// password is checked, so that concurrent attempts cannot all slip past the
+// limits. If the email or the address is blocked it returns how long to wait and
+// no reservation; a blocked attempt costs no password hashing.
+func ReserveLogin(ctx context.Context, email string, ip string) (*LoginReservation, time.Duration, error) {
+	reservation := &LoginReservation{email: email, ip: ip}
+
+	account, wait, err := reserveAttempt(ctx, accountKey(email), accountLoginLimit)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+	reservation.account = account
+
+	address, wait, err := reserveAttempt(ctx, addressKey(ip), addressLoginLimit)
+	if err != nil || wait > 0 {
+		if releaseErr := releaseAttempt(ctx, accountKey(email)); releaseErr != nil {
+			log.Println("login attempt could not be released:", releaseErr)
+		}
+		return nil, wait, err
+	}
+	reservation.address = address
+	return reservation, 0, nil
+}
+
+// reserveAttempt increments the attempts of an unlocked key and returns the
+// number of this attempt, or how long the key is still blocked.
+func reserveAttempt(ctx context.Context, key string, limit loginLimit) (int, time.Duration, error) {
+	var err error
+	for try := 0; try < 3; try++ {
+		now := time.Now()
+		var attempt models.LoginAttempt
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+		err = loginAttemptCollection.FindOneAndUpdate(ctx, bson.M{
+			"key":         key,
+			"lockeduntil": bson.M{"$not": bson.M{"$gt": now}},
+		}, bson.M{
+			"$inc":         bson.M{"failures": 1},
+			"$set":         bson.M{"expiresat": now.Add(loginFailureMemory)},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		}, opts).Decode(&attempt)
+
+		if err == nil {
+			if attempt.Failures > limit.lockAfter {
+				// Earlier attempts that are still being checked already use up the
+				// round; this one would start the lockout if they fail.
+				if err := releaseAttempt(ctx, key); err != nil {
+					return 0, 0, err
+				}
+				return 0, limit.backoff(attempt.Failures), nil
+			}
+			return attempt.Failures, 0, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return 0, 0, err
+		}
+
+		// The key exists but is locked, so the upsert tried to insert it again.
+		err = loginAttemptCollection.FindOne(ctx, bson.M{"key": key}).Decode(&attempt)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return 0, 0, err
+		}
+		if err == nil && attempt.LockedUntil != nil {
+			if wait := time.Until(*attempt.LockedUntil); wait > 0 {
+				return 0, wait, nil
+			}
+		}
+		// The lock ended or a concurrent attempt created the key; try again.
+	}
+	return 0, 0, err
+}
+
+// releaseAttempt takes back an attempt that was reserved but not used up.
+func releaseAttempt(ctx context.Context, key string) error {
+	_, err := loginAttemptCollection.UpdateOne(ctx, bson.M{
+		"key":      key,
+		"failures": bson.M{"$gt": 0},
+	}, bson.M{"$inc": bson.M{"failures": -1}})
+	return err
+}
+
+// backoff returns how long the key is blocked after its nth failure.
+func (limit loginLimit) backoff(failures int) time.Duration {
+	if failures >= limit.lockAfter {
+		return LoginLockout()
+	}
+	if failures <= limit.free {
+		return 0
+	}
+	wait := time.Second << uint(failures-limit.free-1)
+	if lockout := LoginLockout(); wait > lockout {
+		return lockout
+	}
+	return wait
+}
+
+func recordFailure(ctx context.Context, key string, limit loginLimit, failures int, email string, ip string) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"lastfailureat": now}}
+	wait := limit.backoff(failures)
+	if wait > 0 {
+		update["$max"] = bson.M{"lockeduntil": now.Add(wait)}
+	}
+	if failures >= limit.lockAfter {
+		// The lockout ends the round; afterwards the key starts over with its free
+		// attempts.
+		update["$set"] = bson.M{"lastfailureat": now, "failures": 0}
+	}
+	if _, err := loginAttemptCollection.UpdateOne(ctx, bson.M{"key": key}, update); err != nil {
+		return err
+	}
+
+	if failures >= limit.lockAfter {
+		details := fmt.Sprintf("%d failed logins, locked for %s", failures, wait)
+		return RecordAudit(ctx, models.AuditLog{Event: limit.event, Email: email, IP: ip, Details: details})
+	}
+	return nil
+}
+
+// Fail counts the reserved attempt as a failed login for the email and for the
+// address it came from, and blocks them for a while once they have failed too
+// often.
+func (reservation *LoginReservation) Fail(ctx context.Context) error {
+	if err := recordFailure(ctx, accountKey(reservation.email), accountLoginLimit, reservation.account, reservation.email, reservation.ip); err != nil {
+		return err
+	}
+	return recordFailure(ctx, addressKey(reservation.ip), addressLoginLimit, reservation.address, reservation.email, reservation.ip)
+}
+
+// Succeed forgets the failed logins of the account. Failures of the address are
+// kept, so that one known password cannot be used to keep guessing the passwords
+// of other accounts; only the reserved attempt is taken back.
+func (reservation *LoginReservation) Succeed(ctx context.Context) error {
+	if err := ClearLoginFailures(ctx, reservation.email); err != nil {
+		return err
+	}
+	return releaseAttempt(ctx, addressKey(reservation.ip))
+}
+
+// Release takes back the reserved attempt without counting it either way.
+func (reservation *LoginReservation) Release(ctx context.Context) error {
+	if err := releaseAttempt(ctx, accountKey(reservation.email)); err != nil {
+		return err
+	}
+	return releaseAttempt(ctx, addressKey(reservation.ip))
+}
+
+// ClearLoginFailures forgets the failed logins of an account.
+func ClearLoginFailures(ctx context.Context, email string) error {
+	_, err := loginAttemptCollection.DeleteOne(ctx, bson.M{"key": accountKey(email)})
+	return err
+}
+
+// UnlockAccount lifts the lockout of an account and records who did it.
+func UnlockAccount(ctx context.Context, email string, actorID string, ip string) error {
+	if err := ClearLoginFailures(ctx, email); err != nil {
+		return err
+	}
+	return RecordAudit(ctx, models.AuditLog{Event: AuditAccountUnlocked, Email: email, IP: ip, ActorID: actorID})
+}
+
+// RecordAudit stores a security event.
+func RecordAudit(ctx context.Context, entry models.AuditLog) error {
+	entry.ID = primitive.NewObjectID()
+	entry.Email = strings.ToLower(strings.TrimSpace(entry.Email))
+	entry.CreatedAt = time.Now()
+	_, err := auditLogCollection.InsertOne(ctx, entry)
+	return err
+}
+
+// ListAuditLogs returns the latest security events, newest first, optionally only
+// those of one email.
+func ListAuditLogs(ctx context.Context, email string, limit int64) ([]models.AuditLog, error) {
+	filter := bson.M{}
+	if email != "" {
+		filter["email"] = strings.ToLower(strings.TrimSpace(email))
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(limit)
+	cursor, err := auditLogCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := make([]models.AuditLog, 0)
+	err = cursor.All(ctx, &logs)
+	return logs, err
+}
@@ -0,0 +1,95 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReserveLoginCountsConcurrentAttempts(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	email := primitive.NewObjectID().Hex() + "@example.com"
+	ip := "203.0.113.7"
+	defer loginAttemptCollection.DeleteMany(context.Background(), bson.M{"key": bson.M{"$in": []string{accountKey(email), addressKey(ip)}}})
+	defer auditLogCollection.DeleteMany(context.Background(), bson.M{"email": email})
+
+	const attempts = 30
+	type result struct {
+		reservation *LoginReservation
+		wait        time.Duration
+		err         error
+	}
+	results := make(chan result, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			reservation, wait, err := ReserveLogin(ctx, email, ip)
+			results <- result{reservation, wait, err}
+		}()
+	}
+
+	var reservations []*LoginReservation
+	for i := 0; i < attempts; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.reservation != nil {
+			reservations = append(reservations, r.reservation)
+		} else if r.wait <= 0 {
+			t.Fatal("an attempt got neither a reservation nor a wait")
+		}
+	}
+	if len(reservations) != accountLoginLimit.lockAfter {
+		t.Fatalf("%d attempts were let through, want %d", len(reservations), accountLoginLimit.lockAfter)
+	}
+
+	for _, reservation := range reservations {
+		if err := reservation.Fail(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reservation, wait, err := ReserveLogin(ctx, email, ip); err != nil || reservation != nil || wait <= 0 {
+		t.Fatalf("after the lockout: reservation = %v, wait = %v, err = %v", reservation, wait, err)
+	}
+}
+
+func TestLoginReservationSucceedClearsTheAccount(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	email := primitive.NewObjectID().Hex() + "@example.com"
+	ip := "203.0.113.8"
+	defer loginAttemptCollection.DeleteMany(context.Background(), bson.M{"key": bson.M{"$in": []string{accountKey(email), addressKey(ip)}}})
+
+	for i := 0; i < accountLoginLimit.free; i++ {
+		reservation, _, err := ReserveLogin(ctx, email, ip)
+		if err != nil || reservation == nil {
+			t.Fatalf("attempt %d: reservation = %v, err = %v", i+1, reservation, err)
+		}
+		if err := reservation.Fail(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reservation, _, err := ReserveLogin(ctx, email, ip)
+	if err != nil || reservation == nil {
+		t.Fatalf("reservation = %v, err = %v", reservation, err)
+	}
+	if err := reservation.Succeed(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := loginAttemptCollection.CountDocuments(ctx, bson.M{"key": accountKey(email)}); n != 0 {
+		t.Errorf("the account still has %d attempt records", n)
+	}
+	if n, _ := loginAttemptCollection.CountDocuments(ctx, bson.M{"key": addressKey(ip), "failures": accountLoginLimit.free}); n != 1 {
+		t.Error("the address should keep its failures but not the successful attempt")
+	}
+}
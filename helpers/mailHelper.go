@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+var (
+	mailerOnce sync.Once
+	mailer     Mailer
+	mailerErr  error
+)
+
+// OutgoingMailer returns the mailer chosen by MAIL_BACKEND, "log" (the default)
+// or "smtp". It is created on first use, after the environment is loaded.
+func OutgoingMailer() (Mailer, error) {
+	mailerOnce.Do(func() {
+		switch os.Getenv("MAIL_BACKEND") {
+		case "", "log":
+			mailer = LogMailer{}
+		case "smtp":
+			mailer, mailerErr = newSMTPMailer()
+		default:
+			mailerErr = fmt.Errorf("unknown MAIL_BACKEND %q", os.Getenv("MAIL_BACKEND"))
+		}
+	})
+	return mailer, mailerErr
+}
+
+// LogMailer writes emails to the server log instead of sending them. It is meant
+// for development.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, mail Mail) error {
+	log.Printf("mail to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server. The connection is upgraded with
+// STARTTLS when the server offers it; without a username no authentication is
+// done, which suits local mail catchers such as Mailpit.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func newSMTPMailer() (*SMTPMailer, error) {
+	m := &SMTPMailer{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+	if m.Host == "" || m.From == "" {
+		return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are required")
+	}
+	if m.Port == "" {
+		m.Port = "587"
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + m.From + "\r\n")
+	message.WriteString("To: " + mail.To + "\r\n")
+	message.WriteString("Subject: " + mail.Subject + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{mail.To}, []byte(message.String()))
+}
@@ -0,0 +1,147 @@
+package helper
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+)
+
+// smtpSession is what the in-process SMTP server received.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP accepts one connection on listener and speaks just enough SMTP for
+// net/smtp, offering AUTH PLAIN but not STARTTLS.
+func serveSMTP(t *testing.T, listener net.Listener, done chan<- smtpSession) {
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Error(err)
+		close(done)
+		return
+	}
+	defer conn.Close()
+
+	var session smtpSession
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			close(done)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN "):
+			credentials, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			session.auth = string(credentials)
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			session.from = line[len("MAIL FROM:"):]
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			session.to = append(session.to, line[len("RCPT TO:"):])
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					t.Error(err)
+					close(done)
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			session.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			done <- session
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func sendTestMail(t *testing.T, username string) smtpSession {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	done := make(chan smtpSession, 1)
+	go serveSMTP(t, listener, done)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := &SMTPMailer{Host: host, Port: port, Username: username, Password: "secret", From: "menu@example.com"}
+	err = mailer.Send(context.Background(), Mail{
+		To:      "guest@example.com",
+		Subject: "Reset your password",
+		Body:    "Reset code: abc\n\nIt is valid for 30 minutes.\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-done
+}
+
+func TestSMTPMailerSendsMail(t *testing.T) {
+	session := sendTestMail(t, "")
+
+	if session.auth != "" {
+		t.Errorf("authenticated without a username: %q", session.auth)
+	}
+	if session.from != "<menu@example.com>" {
+		t.Errorf("MAIL FROM = %q", session.from)
+	}
+	if len(session.to) != 1 || session.to[0] != "<guest@example.com>" {
+		t.Errorf("RCPT TO = %q", session.to)
+	}
+
+	for _, header := range []string{
+		"From: menu@example.com\r\n",
+		"To: guest@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+	} {
+		if !strings.Contains(session.data, header) {
+			t.Errorf("message lacks %q:\n%s", header, session.data)
+		}
+	}
+	if !strings.HasSuffix(session.data, "\r\n\r\nReset code: abc\r\n\r\nIt is valid for 30 minutes.\r\n") {
+		t.Errorf("body is not CRLF terminated:\n%q", session.data)
+	}
+}
+
+func TestSMTPMailerAuthenticates(t *testing.T) {
+	session := sendTestMail(t, "menu")
+
+	if session.auth != "\x00menu\x00secret" {
+		t.Errorf("AUTH PLAIN credentials = %q", session.auth)
+	}
+}
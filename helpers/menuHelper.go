@@ -0,0 +1,275 @@
+package helper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTransaction runs fn inside a multi-document transaction. MongoDB only
+// supports transactions on replica sets and sharded clusters.
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+func findGroupIDs(ctx context.Context, filter bson.M) ([]string, error) {
+	cursor, err := menuGroupCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID.Hex()
+	}
+	return groupIDs, nil
+}
+
+// Orphans lists groups whose menu no longer exists and items whose group no longer
+// exists. Items of orphaned groups are reported as orphans as well.
+type Orphans struct {
+	GroupIDs []primitive.ObjectID `json:"group_ids"`
+	ItemIDs  []primitive.ObjectID `json:"item_ids"`
+}
+
+func existingIDs(ctx context.Context, collection *mongo.Collection, filter bson.M) (map[string]bool, error) {
+	values, err := collection.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids[id.Hex()] = true
+		}
+	}
+	return ids, nil
+}
+
+func FindOrphans(ctx context.Context) (Orphans, error) {
+	orphans := Orphans{
+		GroupIDs: make([]primitive.ObjectID, 0),
+		ItemIDs:  make([]primitive.ObjectID, 0),
+	}
+
+	menuIDs, err := existingIDs(ctx, menuCollection, bson.M{})
+	if err != nil {
+		return orphans, err
+	}
+
+	var groups []struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		MenuID *string            `bson:"menuid"`
+	}
+	cursor, err := menuGroupCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return orphans, err
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return orphans, err
+	}
+
+	groupIDs := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group.MenuID == nil || !menuIDs[*group.MenuID] {
+			orphans.GroupIDs = append(orphans.GroupIDs, group.ID)
+			continue
+		}
+		groupIDs[group.ID.Hex()] = true
+	}
+
+	var items []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		GroupID *string            `bson:"groupid"`
+	}
+	cursor, err = menuItemCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return orphans, err
+	}
+	if err := cursor.All(ctx, &items); err != nil {
+		return orphans, err
+	}
+
+	for _, item := range items {
+		if item.GroupID == nil || !groupIDs[*item.GroupID] {
+			orphans.ItemIDs = append(orphans.ItemIDs, item.ID)
+		}
+	}
+
+	return orphans, nil
+}
+
+func PurgeOrphans(ctx context.Context, orphans Orphans) (groups int64, items int64, err error) {
+	if len(orphans.ItemIDs) > 0 {
+		result, err := menuItemCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": orphans.ItemIDs}})
+		if err != nil {
+			return 0, 0, err
+		}
+		items = result.DeletedCount
+	}
+
+	if len(orphans.GroupIDs) > 0 {
+		result, err := menuGroupCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": orphans.GroupIDs}})
+		if err != nil {
+			return 0, items, err
+		}
+		groups = result.DeletedCount
+	}
+
+	return groups, items, nil
+}
+
+// PositionSort orders groups and items by their position, falling back to creation
+// order for records that were created before positions existed.
+var PositionSort = bson.D{{Key: "position", Value: 1}, {Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}
+
+// MenuTreePipeline assembles a menu with its groups and their items in a single
+// aggregation, leaving out anything in the trash. Groups and items reference their
+// parent by hex string, so the lookups compare against the stringified parent _id.
+func MenuTreePipeline(menuID primitive.ObjectID) mongo.Pipeline {
+	itemLookup := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "menu-item"},
+		{Key: "let", Value: bson.D{{Key: "groupId", Value: bson.D{{Key: "$toString", Value: "$_id"}}}}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$groupid", "$$groupId"}}}},
+				{Key: "deleted_at", Value: nil},
+			}}},
+			bson.D{{Key: "$sort", Value: PositionSort}},
+		}},
+		{Key: "as", Value: "menuitem"},
+	}}}
+
+	groupLookup := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "menu-group"},
+		{Key: "let", Value: bson.D{{Key: "menuId", Value: bson.D{{Key: "$toString", Value: "$_id"}}}}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$menuid", "$$menuId"}}}},
+				{Key: "deleted_at", Value: nil},
+			}}},
+			bson.D{{Key: "$sort", Value: PositionSort}},
+			itemLookup,
+		}},
+		{Key: "as", Value: "menugroup"},
+	}}}
+
+	return mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "_id", Value: menuID}, {Key: "deleted_at", Value: nil}}}},
+		groupLookup,
+	}
+}
+
+// LoadMenuTree returns the menu with MenuGroup and each group's MenuItem filled in.
+func LoadMenuTree(ctx context.Context, menuID primitive.ObjectID) (models.Menu, error) {
+	cursor, err := menuCollection.Aggregate(ctx, MenuTreePipeline(menuID))
+	if err != nil {
+		return models.Menu{}, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return models.Menu{}, err
+		}
+		return models.Menu{}, mongo.ErrNoDocuments
+	}
+
+	var menu models.Menu
+	if err := cursor.Decode(&menu); err != nil {
+		return models.Menu{}, err
+	}
+	return menu, nil
+}
+
+var ErrInvalidOrder = errors.New("The list must contain every entry exactly once")
+
+// NextPosition returns the position that places a new record after its siblings.
+// It follows the highest position in use, since deleting a record leaves a gap and
+// counting the siblings would hand out a position that is already taken.
+func NextPosition(ctx context.Context, collection *mongo.Collection, parentField string, parentID string) (int, error) {
+	var last struct {
+		Position int
+	}
+	opts := options.FindOne().SetSort(bson.M{"position": -1}).SetProjection(bson.M{"position": 1})
+	err := collection.FindOne(ctx, bson.M{parentField: parentID, "deleted_at": nil}, opts).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return last.Position + 1, nil
+}
+
+func reorder(ctx context.Context, collection *mongo.Collection, parentField string, parentID string, ids []primitive.ObjectID) error {
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		values, err := collection.Distinct(sc, "_id", bson.M{parentField: parentID, "deleted_at": nil})
+		if err != nil {
+			return err
+		}
+		if len(values) != len(ids) {
+			return ErrInvalidOrder
+		}
+
+		siblings := make(map[primitive.ObjectID]bool, len(values))
+		for _, value := range values {
+			if id, ok := value.(primitive.ObjectID); ok {
+				siblings[id] = true
+			}
+		}
+
+		writes := make([]mongo.WriteModel, len(ids))
+		for i, id := range ids {
+			if !siblings[id] {
+				return ErrInvalidOrder
+			}
+			delete(siblings, id)
+			writes[i] = mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id}).
+				SetUpdate(bson.M{"$set": bson.M{"position": i}})
+		}
+
+		if len(writes) == 0 {
+			return nil
+		}
+		_, err = collection.BulkWrite(sc, writes)
+		return err
+	})
+}
+
+// ReorderGroups rewrites the positions of all groups of a menu from an ordered
+// list of their ids.
+func ReorderGroups(ctx context.Context, menuID string, ids []primitive.ObjectID) error {
+	return reorder(ctx, menuGroupCollection, "menuid", menuID, ids)
+}
+
+// ReorderItems rewrites the positions of all items of a group from an ordered
+// list of their ids.
+func ReorderItems(ctx context.Context, groupID string, ids []primitive.ObjectID) error {
+	return reorder(ctx, menuItemCollection, "groupid", groupID, ids)
+}
@@ -0,0 +1,39 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNextPositionFollowsTheHighestPosition(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	menuID := primitive.NewObjectID().Hex()
+	defer menuGroupCollection.DeleteMany(context.Background(), bson.M{"menuid": menuID})
+
+	position, err := NextPosition(ctx, menuGroupCollection, "menuid", menuID)
+	if err != nil || position != 0 {
+		t.Fatalf("empty menu: position = %d, err = %v", position, err)
+	}
+
+	// The group at position 1 was deleted and one in the trash sits highest.
+	_, err = menuGroupCollection.InsertMany(ctx, []interface{}{
+		bson.M{"_id": primitive.NewObjectID(), "menuid": menuID, "position": 0},
+		bson.M{"_id": primitive.NewObjectID(), "menuid": menuID, "position": 2},
+		bson.M{"_id": primitive.NewObjectID(), "menuid": menuID, "position": 7, "deleted_at": time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, err = NextPosition(ctx, menuGroupCollection, "menuid", menuID)
+	if err != nil || position != 3 {
+		t.Errorf("position = %d, err = %v, want 3", position, err)
+	}
+}
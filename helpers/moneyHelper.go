@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sencerarslan/go-app/models"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// DefaultCurrency is used for menus that were created before they had a currency.
+const DefaultCurrency = "TRY"
+
+var ErrNegativeAmount = errors.New("Amount cannot be negative")
+
+func MenuCurrency(menu models.Menu) string {
+	if menu.Currency == "" {
+		return DefaultCurrency
+	}
+	return menu.Currency
+}
+
+// CurrencyDigits returns the number of decimals used by an ISO 4217 currency.
+func CurrencyDigits(code string) int {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return 2
+	}
+	digits, _ := currency.Standard.Rounding(unit)
+	return digits
+}
+
+// ValidateAmount rejects amounts with more decimals than the currency has and,
+// unless allowNegative is set, negative amounts.
+func ValidateAmount(amount models.Money, code string, allowNegative bool) error {
+	if !allowNegative && amount.Sign() < 0 {
+		return ErrNegativeAmount
+	}
+	if digits := CurrencyDigits(code); amount.Scale() > digits {
+		return fmt.Errorf("%s amounts can have at most %d decimals", code, digits)
+	}
+	return nil
+}
+
+// ValidateItemPrices checks the price of an item and the price deltas of its options.
+func ValidateItemPrices(item models.MenuItem, code string) error {
+	if err := ValidateAmount(item.Price, code, false); err != nil {
+		return fmt.Errorf("price: %v", err)
+	}
+	for _, group := range item.OptionGroup {
+		for _, option := range group.Options {
+			if err := ValidateAmount(option.PriceDelta, code, true); err != nil {
+				return fmt.Errorf("option %q: %v", *option.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// FormatMoney renders an amount with the currency symbol and number format of locale.
+func FormatMoney(amount models.Money, code string, locale string) string {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return amount.String()
+	}
+
+	value, err := strconv.ParseFloat(amount.Round(CurrencyDigits(code)).String(), 64)
+	if err != nil {
+		return amount.String()
+	}
+
+	printer := message.NewPrinter(language.Make(locale))
+	return printer.Sprint(currency.Symbol(unit.Amount(value)))
+}
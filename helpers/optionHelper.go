@@ -0,0 +1,87 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnknownOption = errors.New("Unknown option selected")
+
+// PrepareOptionGroups checks the selection rules that the validator tags cannot
+// express and gives new option groups and options their ids. An id may appear
+// only once on an item, since a selection names options by id alone.
+func PrepareOptionGroups(groups []models.OptionGroup) error {
+	seen := make(map[primitive.ObjectID]bool)
+	for i := range groups {
+		group := &groups[i]
+		if group.MaxSelect > len(group.Options) {
+			return fmt.Errorf("option group %q allows %d selections but has only %d options", *group.Name, group.MaxSelect, len(group.Options))
+		}
+
+		if group.ID == primitive.NilObjectID {
+			group.ID = primitive.NewObjectID()
+		} else if seen[group.ID] {
+			return fmt.Errorf("id %s is used more than once in the option groups", group.ID.Hex())
+		}
+		seen[group.ID] = true
+
+		for j := range group.Options {
+			option := &group.Options[j]
+			if option.ID == primitive.NilObjectID {
+				option.ID = primitive.NewObjectID()
+			} else if seen[option.ID] {
+				return fmt.Errorf("id %s is used more than once in the option groups", option.ID.Hex())
+			}
+			seen[option.ID] = true
+		}
+	}
+	return nil
+}
+
+// PriceForSelection checks a combination of option ids against the item's
+// selection rules and returns the final price together with the resolved options.
+func PriceForSelection(item models.MenuItem, optionIDs []primitive.ObjectID) (models.Money, []models.OrderOption, error) {
+	wanted := make(map[primitive.ObjectID]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if wanted[id] {
+			return models.Money{}, nil, fmt.Errorf("option %s is selected more than once", id.Hex())
+		}
+		wanted[id] = true
+	}
+
+	price := item.Price
+	selected := make([]models.OrderOption, 0, len(optionIDs))
+	for _, group := range item.OptionGroup {
+		count := 0
+		for _, option := range group.Options {
+			if !wanted[option.ID] {
+				continue
+			}
+			delete(wanted, option.ID)
+			count++
+			price = price.Add(option.PriceDelta)
+			selected = append(selected, models.OrderOption{
+				GroupID:    group.ID,
+				OptionID:   option.ID,
+				Name:       option.Name,
+				PriceDelta: option.PriceDelta,
+			})
+		}
+
+		if count < group.MinSelect || count > group.MaxSelect {
+			return models.Money{}, nil, fmt.Errorf("option group %q needs between %d and %d selections", *group.Name, group.MinSelect, group.MaxSelect)
+		}
+	}
+
+	if len(wanted) > 0 {
+		return models.Money{}, nil, ErrUnknownOption
+	}
+
+	if price.Sign() < 0 {
+		price = models.Money{}
+	}
+	return price, selected, nil
+}
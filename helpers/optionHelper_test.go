@@ -0,0 +1,119 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func money(t *testing.T, s string) models.Money {
+	t.Helper()
+	m, err := models.ParseMoney(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func option(t *testing.T, name string, delta string) models.Option {
+	t.Helper()
+	return models.Option{ID: primitive.NewObjectID(), Name: &name, PriceDelta: money(t, delta)}
+}
+
+func optionGroup(name string, min, max int, options ...models.Option) models.OptionGroup {
+	return models.OptionGroup{ID: primitive.NewObjectID(), Name: &name, MinSelect: min, MaxSelect: max, Options: options}
+}
+
+func TestPriceForSelection(t *testing.T) {
+	small, large := option(t, "Small", "0"), option(t, "Large", "1.50")
+	shot, oat, syrup := option(t, "Extra shot", "0.75"), option(t, "Oat milk", "0.40"), option(t, "Syrup", "0.30")
+	discount := option(t, "Own cup", "-20")
+	item := models.MenuItem{
+		Price: money(t, "3.20"),
+		OptionGroup: []models.OptionGroup{
+			optionGroup("Size", 1, 1, small, large),
+			optionGroup("Extras", 0, 2, shot, oat, syrup),
+			optionGroup("Cup", 0, 1, discount),
+		},
+	}
+
+	cases := []struct {
+		name      string
+		selection []primitive.ObjectID
+		price     string
+		ok        bool
+	}{
+		{"required group only", []primitive.ObjectID{small.ID}, "3.20", true},
+		{"deltas are added", []primitive.ObjectID{large.ID, shot.ID, oat.ID}, "5.85", true},
+		{"missing required group", []primitive.ObjectID{shot.ID}, "", false},
+		{"too many in one group", []primitive.ObjectID{small.ID, large.ID}, "", false},
+		{"over the group maximum", []primitive.ObjectID{small.ID, shot.ID, oat.ID, syrup.ID}, "", false},
+		{"same option twice", []primitive.ObjectID{small.ID, shot.ID, shot.ID}, "", false},
+		{"unknown option", []primitive.ObjectID{small.ID, primitive.NewObjectID()}, "", false},
+		{"negative total is clamped to zero", []primitive.ObjectID{small.ID, discount.ID}, "0", true},
+	}
+	for _, tc := range cases {
+		price, selected, err := PriceForSelection(item, tc.selection)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("%s: no error, want one", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if price.Add(money(t, "-"+tc.price)).Sign() != 0 {
+			t.Errorf("%s: price = %s, want %s", tc.name, price, tc.price)
+		}
+		if len(selected) != len(tc.selection) {
+			t.Errorf("%s: %d options resolved, want %d", tc.name, len(selected), len(tc.selection))
+		}
+	}
+}
+
+func TestPriceForSelectionCountsAnIdRepeatedAcrossGroupsOnce(t *testing.T) {
+	shared := option(t, "Ice", "0.10")
+	item := models.MenuItem{
+		Price: money(t, "2"),
+		OptionGroup: []models.OptionGroup{
+			optionGroup("Extras", 0, 1, shared),
+			optionGroup("More extras", 0, 1, shared),
+		},
+	}
+
+	price, selected, err := PriceForSelection(item, []primitive.ObjectID{shared.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Add(money(t, "-2.10")).Sign() != 0 || len(selected) != 1 {
+		t.Errorf("price = %s with %d options, want 2.10 with 1", price, len(selected))
+	}
+}
+
+func TestPrepareOptionGroups(t *testing.T) {
+	small, large := option(t, "Small", "0"), option(t, "Large", "1")
+	fresh := option(t, "Fresh", "0")
+	fresh.ID = primitive.NilObjectID
+	groups := []models.OptionGroup{optionGroup("Size", 1, 1, small, large), optionGroup("Extras", 0, 1, fresh)}
+	groups[1].ID = primitive.NilObjectID
+	if err := PrepareOptionGroups(groups); err != nil {
+		t.Fatal(err)
+	}
+	if groups[1].ID.IsZero() || groups[1].Options[0].ID.IsZero() {
+		t.Error("new group and option did not get ids")
+	}
+
+	if err := PrepareOptionGroups([]models.OptionGroup{optionGroup("Size", 1, 3, small, large)}); err == nil {
+		t.Error("a maximum above the number of options was accepted")
+	}
+	if err := PrepareOptionGroups([]models.OptionGroup{optionGroup("Size", 1, 1, small), optionGroup("Again", 0, 1, small)}); err == nil {
+		t.Error("an option id repeated across groups was accepted")
+	}
+	repeated := optionGroup("Size", 1, 1, large)
+	if err := PrepareOptionGroups([]models.OptionGroup{repeated, repeated}); err == nil {
+		t.Error("a repeated group id was accepted")
+	}
+}
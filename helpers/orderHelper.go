@@ -0,0 +1,196 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	OrderReceived  = "received"
+	OrderPreparing = "preparing"
+	OrderReady     = "ready"
+	OrderServed    = "served"
+	OrderPaid      = "paid"
+	OrderCancelled = "cancelled"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	OrderReceived:  {OrderPreparing, OrderCancelled},
+	OrderPreparing: {OrderReady, OrderCancelled},
+	OrderReady:     {OrderServed, OrderCancelled},
+	OrderServed:    {OrderPaid},
+}
+
+var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+
+var ErrOrderRejected = errors.New("Order rejected")
+var ErrInvalidTransition = errors.New("Order status cannot be changed this way")
+
+type OrderLine struct {
+	ItemID   primitive.ObjectID   `json:"item_id"`
+	Quantity int                  `json:"quantity" validate:"gte=1,lte=50"`
+	Options  []primitive.ObjectID `json:"options"`
+	Note     *string              `json:"note" validate:"omitempty,max=200"`
+}
+
+func rejectOrder(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrOrderRejected, fmt.Sprintf(format, args...))
+}
+
+// CanTransition reports whether an order in status from may move to status to.
+func CanTransition(from string, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildOrder prices the requested lines from the published menu. Client supplied
+// prices are never used; every line keeps a snapshot of the name and price it was
+// ordered at. Errors wrapping ErrOrderRejected are caused by the request itself.
+func BuildOrder(ctx context.Context, menuID primitive.ObjectID, lines []OrderLine, now time.Time) (models.Order, error) {
+	var order models.Order
+
+	menu, err := LoadPublishedMenu(ctx, menuID)
+	if err == mongo.ErrNoDocuments || err == ErrNotPublished {
+		return order, ErrNotFound
+	}
+	if err != nil {
+		return order, err
+	}
+
+	local := now.In(MenuLocation(menu))
+	if !IsAvailable(menu.Schedule, local) {
+		return order, rejectOrder("the menu is not available right now")
+	}
+
+	currency := MenuCurrency(menu)
+	digits := CurrencyDigits(currency)
+	total := models.Money{}
+
+	order.Items = make([]models.OrderItem, 0, len(lines))
+	for _, line := range lines {
+		group, item, found := FindPublishedItem(menu, line.ItemID)
+		if !found {
+			return order, rejectOrder("item %s is not on this menu", line.ItemID.Hex())
+		}
+		if item.SoldOut {
+			return order, rejectOrder("%s is sold out", *item.Name)
+		}
+		if !IsAvailable(group.Schedule, local) || !IsAvailable(item.Schedule, local) {
+			return order, rejectOrder("%s is not available right now", *item.Name)
+		}
+
+		price, options, err := PriceForSelection(item, line.Options)
+		if err != nil {
+			return order, rejectOrder("%s: %s", *item.Name, err.Error())
+		}
+		price = price.Round(digits)
+		lineTotal := price.Mul(int64(line.Quantity))
+		total = total.Add(lineTotal)
+
+		order.Items = append(order.Items, models.OrderItem{
+			ItemID:    item.ID.Hex(),
+			Name:      item.Name,
+			Quantity:  line.Quantity,
+			UnitPrice: price,
+			Options:   options,
+			Total:     lineTotal,
+			Note:      line.Note,
+		})
+	}
+
+	order.MenuID = menuID.Hex()
+	order.Currency = currency
+	order.Total = total
+	return order, nil
+}
+
+// PlaceOrder takes the ordered quantities from stock and stores the order in one
+// transaction, so an order is never saved for items that ran out meanwhile.
+func PlaceOrder(ctx context.Context, order *models.Order) error {
+	now := time.Now()
+	order.ID = primitive.NewObjectID()
+	order.Status = OrderReceived
+	order.History = []models.OrderStatus{{Status: OrderReceived, At: now}}
+	order.CreatedAt = now
+	order.UpdatedAt = now
+
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		for _, line := range order.Items {
+			itemID, err := primitive.ObjectIDFromHex(line.ItemID)
+			if err != nil {
+				return err
+			}
+			if err := TakeStock(sc, itemID, int64(line.Quantity)); err == ErrOutOfStock {
+				return rejectOrder("not enough %s left", *line.Name)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		_, err := orderCollection.InsertOne(sc, order)
+		return err
+	})
+}
+
+// SetOrderStatus moves an order to the given status. The update only applies if
+// the order is still in the status it was read in, so two staff members cannot
+// both move the same order. A cancelled order gives its quantities back to stock
+// in the same transaction.
+func SetOrderStatus(ctx context.Context, order models.Order, status string, userID string) (models.Order, error) {
+	if !CanTransition(order.Status, status) {
+		return order, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	change := models.OrderStatus{Status: status, UserID: userID, At: now}
+	filter := bson.M{"_id": order.ID, "status": order.Status}
+	update := bson.M{
+		"$set":  bson.M{"status": status, "updatedat": now},
+		"$push": bson.M{"history": change},
+	}
+
+	err := WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := orderCollection.UpdateOne(sc, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return ErrInvalidTransition
+		}
+		if status != OrderCancelled {
+			return nil
+		}
+
+		for _, line := range order.Items {
+			itemID, err := primitive.ObjectIDFromHex(line.ItemID)
+			if err != nil {
+				return err
+			}
+			if err := RestoreStock(sc, itemID, int64(line.Quantity)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return order, err
+	}
+
+	order.Status = status
+	order.UpdatedAt = now
+	order.History = append(order.History, change)
+	return order, nil
+}
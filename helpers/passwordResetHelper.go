@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var passwordResetCollection *mongo.Collection = database.OpenCollection(database.Client, "password-reset")
+
+var ErrInvalidResetToken = errors.New("The reset link is invalid or has expired")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := passwordResetCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.M{"tokenhash": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.M{"userid": 1}},
+	})
+	if err != nil {
+		log.Println("could not create password-reset indexes:", err)
+	}
+}
+
+// PasswordResetTTL returns how long a reset link stays valid, based on
+// PASSWORD_RESET_MINUTES.
+func PasswordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_MINUTES"))
+	if err != nil || minutes < 1 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// hashResetToken returns the form a reset token is stored in. The token itself
+// is only ever sent to the user.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset issues a new reset token for the user. Earlier unused tokens
+// of the user stop working.
+func CreatePasswordReset(ctx context.Context, userID string) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	if _, err := passwordResetCollection.DeleteMany(ctx, bson.M{"userid": userID, "usedat": nil}); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	reset := models.PasswordReset{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: now.Add(PasswordResetTTL()),
+		CreatedAt: now,
+	}
+	_, err := passwordResetCollection.InsertOne(ctx, reset)
+	return token, err
+}
+
+// SendPasswordReset mails the reset token to the user, as a link when
+// PASSWORD_RESET_URL is set.
+func SendPasswordReset(ctx context.Context, email string, token string) error {
+	mailer, err := OutgoingMailer()
+	if err != nil {
+		return err
+	}
+
+	code := "Reset code: " + token
+	if base := os.Getenv("PASSWORD_RESET_URL"); base != "" {
+		code = base + "?token=" + token
+	}
+	body := fmt.Sprintf("Someone asked to reset the password of your account.\n\n%s\n\n"+
+		"It is valid for %d minutes. If it was not you, you can ignore this email.\n",
+		code, int(PasswordResetTTL().Minutes()))
+
+	return mailer.Send(ctx, Mail{To: email, Subject: "Reset your password", Body: body})
+}
+
+// ResetPassword uses up the reset token and sets the new, already hashed password
+// of its user in one transaction, so a token can change the password only once.
+// It returns the id of the user.
+func ResetPassword(ctx context.Context, token string, hashedPassword string) (string, error) {
+	var reset models.PasswordReset
+	err := WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+		err := passwordResetCollection.FindOneAndUpdate(sc,
+			bson.M{"tokenhash": hashResetToken(token), "usedat": nil, "expiresat": bson.M{"$gt": now}},
+			bson.M{"$set": bson.M{"usedat": now}}).Decode(&reset)
+		if err == mongo.ErrNoDocuments {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := userCollection.UpdateOne(sc, bson.M{"user_id": reset.UserID},
+			bson.M{"$set": bson.M{"password": hashedPassword, "updated_at": now}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInvalidResetToken
+		}
+		return nil
+	})
+	return reset.UserID, err
+}
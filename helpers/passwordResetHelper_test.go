@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestResetPasswordUsesTokenOnce(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	password := "old hash"
+	user := seedUser(t, models.User{Password: &password})
+	defer passwordResetCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	token, err := CreatePasswordReset(ctx, user.User_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := ResetPassword(ctx, token, "new hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != user.User_id {
+		t.Errorf("user id = %q, want %q", userID, user.User_id)
+	}
+
+	if _, err := ResetPassword(ctx, token, "second hash"); err != ErrInvalidResetToken {
+		t.Fatalf("second use: err = %v, want %v", err, ErrInvalidResetToken)
+	}
+
+	var stored models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": user.User_id}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password == nil || *stored.Password != "new hash" {
+		t.Errorf("password = %v, want the one set by the first reset", stored.Password)
+	}
+}
+
+func TestResetPasswordUsesTokenOnceUnderConcurrency(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUser(t, models.User{})
+	defer passwordResetCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	token, err := CreatePasswordReset(ctx, user.User_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 5
+	results := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			_, err := ResetPassword(ctx, token, "new hash")
+			results <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < attempts; i++ {
+		switch err := <-results; err {
+		case nil:
+			succeeded++
+		case ErrInvalidResetToken:
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d resets succeeded, want 1", succeeded)
+	}
+}
+
+func TestResetPasswordRejectsExpiredTokens(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUser(t, models.User{})
+	defer passwordResetCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	token, err := CreatePasswordReset(ctx, user.User_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = passwordResetCollection.UpdateOne(ctx, bson.M{"tokenhash": hashResetToken(token)},
+		bson.M{"$set": bson.M{"expiresat": time.Now().Add(-time.Second)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ResetPassword(ctx, token, "new hash"); err != ErrInvalidResetToken {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
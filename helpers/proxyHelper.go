@@ -0,0 +1,20 @@
+package helper
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies returns the addresses or CIDR ranges listed in TRUSTED_PROXIES,
+// separated by commas. Only requests arriving from these proxies may set the
+// client address through X-Forwarded-For or X-Real-IP. With none configured,
+// gin's ClientIP is the address of the connection itself.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
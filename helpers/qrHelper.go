@@ -0,0 +1,434 @@
+package helper
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+)
+
+const maxLogoBytes = 5 << 20
+
+// sheetFont is a Unicode font for the labels on QR sheets. The core PDF fonts only
+// cover cp1252, which lacks Turkish letters such as ş, ğ and ı.
+//
+//go:embed fonts/DejaVuSansCondensed.ttf
+var sheetFont []byte
+
+// QROptions controls how a QR code is rendered. Logo is drawn in the middle of the
+// code on a background-colored square.
+type QROptions struct {
+	Size       int
+	Level      qrcode.RecoveryLevel
+	Foreground color.Color
+	Background color.Color
+	Logo       image.Image
+}
+
+// QRSheetEntry is one labelled code on a printable sheet.
+type QRSheetEntry struct {
+	Label   string
+	Content string
+}
+
+// MenuURL returns the public address of a menu, based on PUBLIC_MENU_URL.
+func MenuURL(menuID string) string {
+	base := os.Getenv("PUBLIC_MENU_URL")
+	if base == "" {
+		base = "https://qr-menu-wheat.vercel.app/qr/"
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base + menuID
+}
+
+func ParseRecoveryLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "", "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return qrcode.Medium, errors.New("level must be one of L, M, Q or H")
+}
+
+// ParseHexColor parses colors written as RGB or RRGGBB, with or without a leading #.
+func ParseHexColor(value string) (color.RGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", value)
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+const logoCacheTTL = 10 * time.Minute
+const logoCacheSize = 256
+
+var ErrLogoAddress = errors.New("logo must be served from a public address")
+var ErrLogoTooLarge = errors.New("logo is too large")
+
+// logoClient only connects to public addresses. The check runs on the address
+// actually dialed, after DNS resolution and on every redirect, so a host name that
+// resolves to an internal address cannot reach it either.
+var logoClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: dialPublicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+}
+
+// nonPublicNetworks are the ranges that IsLoopback, IsLinkLocalUnicast and the
+// other net.IP methods leave out.
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func dialPublicOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return ErrLogoAddress
+	}
+	return nil
+}
+
+type cachedLogo struct {
+	logo    image.Image
+	err     error
+	expires time.Time
+}
+
+var (
+	logoCacheMu sync.Mutex
+	logoCache   = make(map[string]cachedLogo)
+)
+
+// FetchLogo returns the decoded PNG, JPEG or GIF logo at url. Logos of the local
+// upload backend are read from disk; other URLs are downloaded with logoClient.
+// Results, failures included, are kept for logoCacheTTL so that serving QR codes
+// does not download the logo again for every request.
+func FetchLogo(url string) (image.Image, error) {
+	logoCacheMu.Lock()
+	cached, found := logoCache[url]
+	logoCacheMu.Unlock()
+	if found && time.Now().Before(cached.expires) {
+		return cached.logo, cached.err
+	}
+
+	logo, err := loadLogo(url)
+
+	logoCacheMu.Lock()
+	defer logoCacheMu.Unlock()
+	if len(logoCache) >= logoCacheSize {
+		now := time.Now()
+		for key, entry := range logoCache {
+			if now.After(entry.expires) {
+				delete(logoCache, key)
+			}
+		}
+		// Every entry is still fresh: drop arbitrary ones to make room.
+		for key := range logoCache {
+			if len(logoCache) < logoCacheSize {
+				break
+			}
+			delete(logoCache, key)
+		}
+	}
+	logoCache[url] = cachedLogo{logo: logo, err: err, expires: time.Now().Add(logoCacheTTL)}
+	return logo, err
+}
+
+func loadLogo(url string) (image.Image, error) {
+	data, err := readLogo(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is enough to tell the size, before the pixels are allocated.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, ErrLogoTooLarge
+	}
+
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	return logo, err
+}
+
+func readLogo(url string) ([]byte, error) {
+	if data, found, err := readLocalUpload(url); found {
+		return data, err
+	}
+
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, errors.New("logo must be an http or https URL")
+	}
+
+	resp, err := logoClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("logo could not be downloaded: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxLogoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxLogoBytes {
+		return nil, ErrLogoTooLarge
+	}
+	return data, nil
+}
+
+// readLocalUpload reads a file of the local upload backend straight from
+// UPLOAD_DIR. found is false if url is not one of its files.
+func readLocalUpload(url string) (data []byte, found bool, err error) {
+	dir, local := LocalUploadDir()
+	prefix := localUploadURL() + "/"
+	if !local || !strings.HasPrefix(url, prefix) {
+		return nil, false, nil
+	}
+
+	path, err := (&LocalStorage{Dir: dir}).path(strings.TrimPrefix(url, prefix))
+	if err != nil {
+		return nil, true, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, true, err
+	}
+	if info.Size() > maxLogoBytes {
+		return nil, true, ErrLogoTooLarge
+	}
+	data, err = ioutil.ReadFile(path)
+	return data, true, err
+}
+
+func newQRCode(content string, opts QROptions) (*qrcode.QRCode, error) {
+	level := opts.Level
+	if opts.Logo != nil {
+		// The logo hides the middle of the code, which only the highest level survives.
+		level = qrcode.Highest
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Foreground != nil {
+		code.ForegroundColor = opts.Foreground
+	}
+	if opts.Background != nil {
+		code.BackgroundColor = opts.Background
+	}
+	return code, nil
+}
+
+// logoBox returns the square, a fifth of the code's width, that holds the logo.
+func logoBox(size int) image.Rectangle {
+	side := size / 5
+	offset := (size - side) / 2
+	return image.Rect(offset, offset, offset+side, offset+side)
+}
+
+func fitLogo(logo image.Image, box image.Rectangle) image.Rectangle {
+	bounds := logo.Bounds()
+	padding := box.Dx() / 10
+	inner := box.Inset(padding)
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return inner
+	}
+
+	if bounds.Dx() > bounds.Dy() {
+		height := inner.Dx() * bounds.Dy() / bounds.Dx()
+		offset := (inner.Dy() - height) / 2
+		return image.Rect(inner.Min.X, inner.Min.Y+offset, inner.Max.X, inner.Min.Y+offset+height)
+	}
+	width := inner.Dy() * bounds.Dx() / bounds.Dy()
+	offset := (inner.Dx() - width) / 2
+	return image.Rect(inner.Min.X+offset, inner.Min.Y, inner.Min.X+offset+width, inner.Max.Y)
+}
+
+func QRCodePNG(content string, opts QROptions) ([]byte, error) {
+	code, err := newQRCode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Logo == nil {
+		return code.PNG(opts.Size)
+	}
+
+	img := code.Image(opts.Size)
+	canvas := image.NewRGBA(img.Bounds())
+	stddraw.Draw(canvas, canvas.Bounds(), img, image.Point{}, stddraw.Src)
+
+	box := logoBox(canvas.Bounds().Dx())
+	stddraw.Draw(canvas, box, image.NewUniform(code.BackgroundColor), image.Point{}, stddraw.Src)
+	draw.CatmullRom.Scale(canvas, fitLogo(opts.Logo, box), opts.Logo, opts.Logo.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func svgColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+func QRCodeSVG(content string, opts QROptions) ([]byte, error) {
+	code, err := newQRCode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, svgColor(code.BackgroundColor))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, svgColor(code.ForegroundColor))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		box := logoBox(modules * 100)
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, opts.Logo); err != nil {
+			return nil, err
+		}
+		inner := fitLogo(opts.Logo, box)
+		fmt.Fprintf(&buf, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`,
+			float64(box.Min.X)/100, float64(box.Min.Y)/100, float64(box.Dx())/100, float64(box.Dy())/100, svgColor(code.BackgroundColor))
+		fmt.Fprintf(&buf, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`,
+			float64(inner.Min.X)/100, float64(inner.Min.Y)/100, float64(inner.Dx())/100, float64(inner.Dy())/100,
+			base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// QRSheetPDF lays out one code per entry on A4 pages, three across and four down,
+// with the entry's label under each code.
+func QRSheetPDF(title string, entries []QRSheetEntry, opts QROptions) ([]byte, error) {
+	const columns, rows = 3, 4
+	const cellWidth, cellHeight, codeSize = 60.0, 68.0, 50.0
+	const marginX, marginY = 15.0, 16.0
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.AddUTF8FontFromBytes("DejaVu", "", sheetFont)
+	pdf.SetFont("DejaVu", "", 11)
+
+	for i, entry := range entries {
+		if i%(columns*rows) == 0 {
+			pdf.AddPage()
+		}
+		cell := i % (columns * rows)
+		x := marginX + float64(cell%columns)*cellWidth
+		y := marginY + float64(cell/columns)*cellHeight
+
+		code, err := QRCodePNG(entry.Content, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("qr-%d", i)
+		pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(code))
+		pdf.ImageOptions(name, x+(cellWidth-codeSize)/2, y, codeSize, codeSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetXY(x, y+codeSize+2)
+		pdf.CellFormat(cellWidth, 6, entry.Label, "", 0, "C", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
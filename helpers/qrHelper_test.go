@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.20.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+	}
+	for address, public := range tests {
+		if got := isPublicIP(net.ParseIP(address)); got != public {
+			t.Errorf("isPublicIP(%s) = %v, want %v", address, got, public)
+		}
+	}
+}
+
+func TestFetchLogoRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the logo request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := FetchLogo(server.URL + "/logo.png")
+	if !errors.Is(err, ErrLogoAddress) {
+		t.Fatalf("err = %v, want %v", err, ErrLogoAddress)
+	}
+}
+
+// pngHeader returns the start of a PNG that claims the given size. It holds no
+// pixels, which is all DecodeConfig needs.
+func pngHeader(width uint32, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 bit RGBA
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestFetchLogoReadsLocalUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("UPLOAD_DIR", dir)
+	defer os.Unsetenv("UPLOAD_DIR")
+
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "logo.png"), logo.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "bomb.png"), pngHeader(100000, 100000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := FetchLogo(localUploadURL() + "/logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := decoded.Bounds().Size(); size != image.Pt(4, 2) {
+		t.Errorf("logo size = %v, want 4x2", size)
+	}
+
+	if _, err := FetchLogo(localUploadURL() + "/bomb.png"); err != ErrLogoTooLarge {
+		t.Errorf("err = %v, want %v", err, ErrLogoTooLarge)
+	}
+}
+
+func TestQRSheetPDFPrintsTurkishLabels(t *testing.T) {
+	label := "Şiş Köfte İğdır Masası"
+	sheet, err := QRSheetPDF("Masalar", []QRSheetEntry{{Label: label, Content: MenuURL("menu")}}, QROptions{Size: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Text in a UTF-8 font is written as UTF-16BE inside the compressed page stream.
+	var want bytes.Buffer
+	for _, unit := range utf16.Encode([]rune(label)) {
+		binary.Write(&want, binary.BigEndian, unit)
+	}
+	for rest := sheet; ; {
+		start := bytes.Index(rest, []byte(">>\nstream\n"))
+		if start < 0 {
+			break
+		}
+		rest = rest[start+len(">>\nstream\n"):]
+		end := bytes.Index(rest, []byte("\nendstream"))
+		if end < 0 {
+			break
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(rest[:end]))
+		if err != nil {
+			continue
+		}
+		content, _ := ioutil.ReadAll(reader)
+		if bytes.Contains(content, want.Bytes()) {
+			return
+		}
+	}
+	t.Errorf("sheet does not contain the label %q", label)
+}
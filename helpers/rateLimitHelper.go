@@ -0,0 +1,221 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitPolicy is a token bucket that holds Limit requests and refills at
+// Limit requests per Period. A Limit of zero turns the policy off.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitResult tells whether a request may pass and how the bucket stands
+// after it.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the buckets. Deployments with several instances need a
+// store they all share, so that a client cannot multiply its limit by the number
+// of instances.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// RateLimitPolicyFor returns the named policy, read from RATE_LIMIT_<NAME> in the
+// form "limit/period", for example RATE_LIMIT_AUTH=10/1m. The defaults are used
+// when the variable is not set or cannot be read.
+func RateLimitPolicyFor(name string, limit int, period time.Duration) RateLimitPolicy {
+	policy := RateLimitPolicy{Name: name, Limit: limit, Period: period}
+
+	value := os.Getenv("RATE_LIMIT_" + strings.ToUpper(name))
+	if value == "" {
+		return policy
+	}
+	parts := strings.SplitN(value, "/", 2)
+	configured, err := strconv.Atoi(parts[0])
+	if err != nil || configured < 0 || len(parts) != 2 {
+		log.Printf("ignoring invalid RATE_LIMIT_%s %q", strings.ToUpper(name), value)
+		return policy
+	}
+	configuredPeriod, err := time.ParseDuration(parts[1])
+	if err != nil || configuredPeriod <= 0 {
+		log.Printf("ignoring invalid RATE_LIMIT_%s %q", strings.ToUpper(name), value)
+		return policy
+	}
+	policy.Limit = configured
+	policy.Period = configuredPeriod
+	return policy
+}
+
+// rate returns how many tokens the bucket gains per second.
+func (policy RateLimitPolicy) rate() float64 {
+	return float64(policy.Limit) / policy.Period.Seconds()
+}
+
+// result describes a bucket that holds tokens after the request was counted.
+func (policy RateLimitPolicy) result(allowed bool, tokens float64) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(policy.Limit) - tokens) / policy.rate() * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / policy.rate() * float64(time.Second))
+	}
+	return result
+}
+
+var (
+	rateLimitStoreOnce sync.Once
+	rateLimitStore     RateLimitStore
+	rateLimitStoreErr  error
+)
+
+// SharedRateLimitStore returns the store chosen by RATE_LIMIT_STORE, "memory"
+// (the default) or "mongo". It is created on first use, after the environment
+// is loaded.
+func SharedRateLimitStore() (RateLimitStore, error) {
+	rateLimitStoreOnce.Do(func() {
+		switch os.Getenv("RATE_LIMIT_STORE") {
+		case "", "memory":
+			rateLimitStore = NewMemoryRateLimitStore()
+		case "mongo":
+			rateLimitStore = newMongoRateLimitStore()
+		default:
+			rateLimitStoreErr = fmt.Errorf("unknown RATE_LIMIT_STORE %q", os.Getenv("RATE_LIMIT_STORE"))
+		}
+	})
+	return rateLimitStore, rateLimitStoreErr
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryRateLimitStore keeps the buckets in the memory of one instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket), lastSweep: time.Now()}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	bucket, found := s.buckets[key]
+	if !found {
+		bucket = &memoryBucket{tokens: float64(policy.Limit), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(policy.Limit), bucket.tokens+now.Sub(bucket.updated).Seconds()*policy.rate())
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	result := policy.result(allowed, bucket.tokens)
+	bucket.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops the buckets that have filled up again, since a new bucket starts
+// full anyway. It runs at most once a minute.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// MongoRateLimitStore keeps the buckets in MongoDB so that all instances share
+// them. Each request updates its bucket with one atomic pipeline update, which
+// needs MongoDB 4.2 or later.
+type MongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+func newMongoRateLimitStore() *MongoRateLimitStore {
+	collection := database.OpenCollection(database.Client, "rate-limit")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresat": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Println("could not create rate-limit indexes:", err)
+	}
+	return &MongoRateLimitStore{collection: collection}
+}
+
+func (s *MongoRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	now := time.Now()
+	limit := float64(policy.Limit)
+	refilled := bson.M{"$min": bson.A{limit, bson.M{"$add": bson.A{
+		bson.M{"$ifNull": bson.A{"$tokens", limit}},
+		bson.M{"$multiply": bson.A{
+			bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updatedat", now}}}},
+			policy.rate() / 1000,
+		}},
+	}}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled, "updatedat": now, "expiresat": now.Add(policy.Period)}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}}}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var bucket struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two instances created the bucket at the same time; the loser retries
+		// against the bucket the winner created.
+		err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return policy.result(bucket.Allowed, bucket.Tokens), nil
+}
@@ -0,0 +1,93 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// takeAll asks store for n tokens of key and returns how many were granted.
+func takeAll(t *testing.T, store RateLimitStore, key string, policy RateLimitPolicy, n int) int {
+	t.Helper()
+	granted := 0
+	for i := 0; i < n; i++ {
+		result, err := store.Take(context.Background(), key, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed {
+			granted++
+		}
+	}
+	return granted
+}
+
+func TestMemoryRateLimitStoreAllowsABurstThenRefills(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Name: "test", Limit: 5, Period: 500 * time.Millisecond}
+
+	if granted := takeAll(t, store, "client", policy, 8); granted != 5 {
+		t.Fatalf("burst: granted %d, want 5", granted)
+	}
+	result, err := store.Take(context.Background(), "client", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter <= 0 || result.RetryAfter > 100*time.Millisecond {
+		t.Errorf("empty bucket: %+v, want refused with a retry within one token's time", result)
+	}
+	if granted := takeAll(t, store, "other", policy, 1); granted != 1 {
+		t.Errorf("another client was refused")
+	}
+
+	// Two of the five tokens come back in 200ms.
+	time.Sleep(220 * time.Millisecond)
+	if granted := takeAll(t, store, "client", policy, 5); granted != 2 {
+		t.Errorf("after refilling: granted %d, want 2", granted)
+	}
+
+	// The bucket never holds more than Limit tokens, however long it waits.
+	time.Sleep(time.Second)
+	if granted := takeAll(t, store, "client", policy, 8); granted != 5 {
+		t.Errorf("after a long wait: granted %d, want 5", granted)
+	}
+}
+
+func TestMongoRateLimitStoresShareBuckets(t *testing.T) {
+	requireDatabase(t)
+	first, second := newMongoRateLimitStore(), newMongoRateLimitStore()
+	policy := RateLimitPolicy{Name: "test", Limit: 4, Period: time.Minute}
+	key := "test:" + primitive.NewObjectID().Hex()
+	defer first.collection.DeleteOne(context.Background(), bson.M{"_id": key})
+
+	granted := takeAll(t, first, key, policy, 3) + takeAll(t, second, key, policy, 3)
+	if granted != 4 {
+		t.Errorf("granted %d across two stores, want 4", granted)
+	}
+}
+
+func TestAPIKeyIDAcceptsOnlyConfiguredKeys(t *testing.T) {
+	os.Setenv("API_KEYS", "first-key, second-key")
+	defer os.Unsetenv("API_KEYS")
+
+	first, ok := APIKeyID("first-key")
+	if !ok {
+		t.Fatal("first-key was refused")
+	}
+	second, ok := APIKeyID("second-key")
+	if !ok {
+		t.Fatal("second-key was refused")
+	}
+	if first == second || first == "first-key" {
+		t.Errorf("ids %q and %q should differ and not contain the key", first, second)
+	}
+	for _, key := range []string{"", "made-up-key", "first-key "} {
+		if _, ok := APIKeyID(key); ok {
+			t.Errorf("APIKeyID(%q) was accepted", key)
+		}
+	}
+}
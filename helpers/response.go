@@ -66,3 +66,10 @@ func TooManyRequestsResponse(data interface{}, message string) Response {
 	}
 	return NewResponse(data, message, false)
 }
+
+func ForbiddenResponse(data interface{}, message string) Response {
+	if message == "" {
+		message = "Unauthorized to access this resource"
+	}
+	return NewResponse(data, message, false)
+}
@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokenCollection *mongo.Collection = database.OpenCollection(database.Client, "revoked-token")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := revokedTokenCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{Keys: bson.M{"jti": 1}},
+		{Keys: bson.M{"userid": 1}},
+	})
+	if err != nil {
+		log.Println("could not create revoked-token indexes:", err)
+	}
+}
+
+// RevokeToken revokes a single token until it would have expired on its own.
+func RevokeToken(jti string, userId string, expiresAt int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	record := models.RevokedToken{
+		ID:        primitive.NewObjectID(),
+		Jti:       jti,
+		UserID:    userId,
+		ExpiresAt: time.Unix(expiresAt, 0),
+		CreatedAt: time.Now(),
+	}
+
+	_, err := revokedTokenCollection.InsertOne(ctx, record)
+	return err
+}
+
+// RevokeAllTokens revokes every token issued to the user so far and ends all of
+// the user's sessions, so no device can mint new access tokens either.
+func RevokeAllTokens(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	// Tokens carry their issue time in milliseconds, as does the cut-off.
+	now := time.Now().Truncate(time.Millisecond)
+	record := models.RevokedToken{
+		ID:           primitive.NewObjectID(),
+		UserID:       userId,
+		IssuedBefore: now,
+		ExpiresAt:    now.Add(RefreshTokenTTL),
+		CreatedAt:    now,
+	}
+
+	if _, err := revokedTokenCollection.InsertOne(ctx, record); err != nil {
+		return err
+	}
+	return ClearTokens(userId)
+}
+
+func IsTokenRevoked(claims *SignedDetails) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	issuedBefore := bson.M{"userid": claims.Uid, "issuedbefore": bson.M{"$gt": claims.IssuedTime()}}
+	filter := bson.M{
+		"$or": []bson.M{
+			{"jti": claims.Id, "userid": claims.Uid},
+			issuedBefore,
+		},
+	}
+	if claims.Id == "" {
+		filter = issuedBefore
+	}
+
+	count, err := revokedTokenCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
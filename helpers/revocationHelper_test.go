@@ -0,0 +1,58 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRevokeAllTokensSeparatesTokensWithinASecond(t *testing.T) {
+	requireDatabase(t)
+	userID := primitive.NewObjectID().Hex()
+	defer revokedTokenCollection.DeleteMany(context.Background(), bson.M{"userid": userID})
+
+	issued := func() *SignedDetails {
+		claims := &SignedDetails{Uid: userID}
+		claims.Id = primitive.NewObjectID().Hex()
+		now := time.Now()
+		claims.Issued_at_ms = unixMillis(now)
+		claims.IssuedAt = now.Unix()
+		return claims
+	}
+
+	// The cut-off has millisecond precision, so tokens a few milliseconds apart
+	// land on either side of it.
+	before := issued()
+	time.Sleep(2 * time.Millisecond)
+	if err := RevokeAllTokens(userID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	after := issued()
+
+	if revoked, err := IsTokenRevoked(before); err != nil || !revoked {
+		t.Errorf("token issued before logging out everywhere: revoked = %v, err = %v", revoked, err)
+	}
+	if revoked, err := IsTokenRevoked(after); err != nil || revoked {
+		t.Errorf("token issued afterwards: revoked = %v, err = %v", revoked, err)
+	}
+}
+
+func TestIssuedTime(t *testing.T) {
+	issued := time.Date(2026, 10, 18, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+
+	claims := &SignedDetails{Issued_at_ms: unixMillis(issued)}
+	claims.IssuedAt = issued.Unix()
+	if got := claims.IssuedTime(); !got.Equal(issued) {
+		t.Errorf("IssuedTime() = %v, want %v", got, issued)
+	}
+
+	// Tokens issued before the millisecond claim only have whole seconds.
+	claims.Issued_at_ms = 0
+	if got, want := claims.IssuedTime(), issued.Truncate(time.Second); !got.Equal(want) {
+		t.Errorf("IssuedTime() = %v, want %v", got, want)
+	}
+}
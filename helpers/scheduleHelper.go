@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+)
+
+const dateLayout = "2006-01-02"
+const clockLayout = "15:04"
+
+// DefaultTimezone is used for menus that were created before they had a timezone.
+const DefaultTimezone = "Europe/Istanbul"
+
+var ErrInvalidOverride = errors.New("Override must not end before it starts")
+
+func MenuLocation(menu models.Menu) *time.Location {
+	name := menu.Timezone
+	if name == "" {
+		name = DefaultTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// ValidateSchedule checks what the validator tags cannot express.
+func ValidateSchedule(schedule *models.Schedule) error {
+	if schedule == nil {
+		return nil
+	}
+	for _, override := range schedule.Overrides {
+		if override.To < override.From {
+			return ErrInvalidOverride
+		}
+	}
+	return nil
+}
+
+func minuteOfDay(clock string) int {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+func hasDay(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// inWindows reports whether t falls into one of the windows. A window whose end is
+// before its start runs past midnight into the next day; equal start and end cover
+// the whole day.
+func inWindows(windows []models.TimeWindow, t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+
+	for _, window := range windows {
+		start, end := minuteOfDay(window.Start), minuteOfDay(window.End)
+		switch {
+		case start == end:
+			if hasDay(window.Days, t.Weekday()) {
+				return true
+			}
+		case start < end:
+			if hasDay(window.Days, t.Weekday()) && minute >= start && minute < end {
+				return true
+			}
+		default:
+			if hasDay(window.Days, t.Weekday()) && minute >= start {
+				return true
+			}
+			if hasDay(window.Days, yesterday) && minute < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsAvailable evaluates a schedule at t, which must already be in the menu's
+// timezone. A nil schedule or one without windows is always available; a date
+// override replaces the weekly windows for the days it covers.
+func IsAvailable(schedule *models.Schedule, t time.Time) bool {
+	if schedule == nil {
+		return true
+	}
+
+	date := t.Format(dateLayout)
+	for _, override := range schedule.Overrides {
+		if date < override.From || date > override.To {
+			continue
+		}
+		if !override.Available {
+			return false
+		}
+		return len(override.Windows) == 0 || inWindows(override.Windows, t)
+	}
+
+	return len(schedule.Windows) == 0 || inWindows(schedule.Windows, t)
+}
@@ -0,0 +1,112 @@
+package helper
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/sencerarslan/go-app/models"
+)
+
+// at returns the given local time in Europe/Istanbul, the default menu timezone.
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", value, MenuLocation(models.Menu{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestIsAvailableAcrossMidnight(t *testing.T) {
+	// Friday and Saturday nights, 22:00 until 02:00.
+	late := &models.Schedule{Windows: []models.TimeWindow{
+		{Days: []time.Weekday{time.Friday, time.Saturday}, Start: "22:00", End: "02:00"},
+	}}
+
+	cases := map[string]bool{
+		"2026-10-16 21:59": false, // Friday
+		"2026-10-16 22:00": true,
+		"2026-10-16 23:59": true,
+		"2026-10-17 00:00": true, // Saturday, still Friday's night
+		"2026-10-17 01:59": true,
+		"2026-10-17 02:00": false,
+		"2026-10-18 01:00": true,  // Sunday, Saturday's night
+		"2026-10-18 22:30": false, // Sunday has no window of its own
+		"2026-10-19 01:00": false, // Monday
+	}
+	for value, want := range cases {
+		if got := IsAvailable(late, at(t, value)); got != want {
+			t.Errorf("%s: available = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestIsAvailableWithDateOverrides(t *testing.T) {
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	breakfast := &models.Schedule{
+		Windows: []models.TimeWindow{{Days: weekdays, Start: "07:00", End: "11:00"}},
+		Overrides: []models.DateOverride{
+			// Closed over the holiday, open late on its eve.
+			{From: "2026-10-29", To: "2026-10-30", Available: false},
+			{From: "2026-10-28", To: "2026-10-28", Available: true, Windows: []models.TimeWindow{
+				{Days: weekdays, Start: "09:00", End: "12:00"},
+			}},
+			// Open all day on a Saturday.
+			{From: "2026-10-31", To: "2026-10-31", Available: true},
+		},
+	}
+
+	cases := map[string]bool{
+		"2026-10-27 08:00": true,  // regular Tuesday
+		"2026-10-27 11:30": false, // after breakfast
+		"2026-10-28 08:00": false, // override starts later
+		"2026-10-28 11:30": true,  // and ends later
+		"2026-10-29 08:00": false, // holiday
+		"2026-10-30 08:00": false, // last day of the holiday
+		"2026-10-31 20:00": true,  // Saturday opened without windows
+		"2026-11-01 08:00": false, // regular Sunday
+		"2026-11-02 08:00": true,  // regular Monday
+	}
+	for value, want := range cases {
+		if got := IsAvailable(breakfast, at(t, value)); got != want {
+			t.Errorf("%s: available = %v, want %v", value, got, want)
+		}
+	}
+
+	if err := ValidateSchedule(&models.Schedule{Overrides: []models.DateOverride{{From: "2026-10-30", To: "2026-10-29"}}}); err != ErrInvalidOverride {
+		t.Errorf("override ending before it starts: err = %v, want %v", err, ErrInvalidOverride)
+	}
+}
+
+func TestIsAvailableFollowsDaylightSavingInTheMenuTimezone(t *testing.T) {
+	berlin := MenuLocation(models.Menu{Timezone: "Europe/Berlin"})
+	if berlin.String() != "Europe/Berlin" {
+		t.Fatalf("MenuLocation = %s, want Europe/Berlin", berlin)
+	}
+	everyDay := []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	breakfast := &models.Schedule{Windows: []models.TimeWindow{{Days: everyDay, Start: "07:00", End: "11:00"}}}
+
+	// Berlin moves from UTC+1 to UTC+2 on 29 March 2026 and back on 25 October.
+	// Each instant would get the other answer with the previous day's offset.
+	cases := []struct {
+		utc  string
+		want bool
+	}{
+		{"2026-03-28T09:30:00Z", true},  // 10:30 CET
+		{"2026-03-29T09:30:00Z", false}, // 11:30 CEST
+		{"2026-03-29T05:30:00Z", true},  // 07:30 CEST
+		{"2026-10-24T05:30:00Z", true},  // 07:30 CEST
+		{"2026-10-25T05:30:00Z", false}, // 06:30 CET
+		{"2026-10-25T09:30:00Z", true},  // 10:30 CET
+	}
+	for _, tc := range cases {
+		instant, err := time.Parse(time.RFC3339, tc.utc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsAvailable(breakfast, instant.In(berlin)); got != tc.want {
+			t.Errorf("%s (%s local): available = %v, want %v", tc.utc, instant.In(berlin).Format("15:04 MST"), got, tc.want)
+		}
+	}
+}
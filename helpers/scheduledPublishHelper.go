@@ -0,0 +1,246 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PublishPending   = "pending"
+	PublishRunning   = "running"
+	PublishDone      = "published"
+	PublishCancelled = "cancelled"
+	PublishFailed    = "failed"
+)
+
+// ScheduleLayout is the format of scheduled times, read in the menu's timezone.
+const ScheduleLayout = "2006-01-02 15:04"
+
+// publishClaimTimeout is how long a claimed publish may run before another
+// instance assumes its claimer died and takes it over.
+const publishClaimTimeout = 5 * time.Minute
+
+var scheduledPublishCollection *mongo.Collection = database.OpenCollection(database.Client, "scheduled-publish")
+
+var (
+	ErrScheduleInPast = errors.New("The publish time must be in the future")
+	ErrScheduleClosed = errors.New("The scheduled publish is no longer pending")
+	errClaimLost      = errors.New("scheduled publish was claimed by another instance")
+)
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := scheduledPublishCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "publishat", Value: 1}},
+	})
+	if err != nil {
+		log.Println("could not create scheduled-publish indexes:", err)
+	}
+}
+
+// scheduleTime reads a local time in the menu's timezone and checks that it has
+// not passed yet.
+func scheduleTime(menu models.Menu, localTime string) (time.Time, error) {
+	at, err := time.ParseInLocation(ScheduleLayout, localTime, MenuLocation(menu))
+	if err != nil {
+		return at, err
+	}
+	if !at.After(time.Now()) {
+		return at, ErrScheduleInPast
+	}
+	return at, nil
+}
+
+// SchedulePublish takes a copy of the current draft of the menu and publishes it
+// at localTime in the menu's timezone. Later changes to the draft are not part of
+// the scheduled publish.
+func SchedulePublish(ctx context.Context, menuID primitive.ObjectID, userID string, localTime string, note *string) (models.ScheduledPublish, error) {
+	var scheduled models.ScheduledPublish
+	draft, err := LoadMenuTree(ctx, menuID)
+	if err != nil {
+		return scheduled, err
+	}
+	at, err := scheduleTime(draft, localTime)
+	if err != nil {
+		return scheduled, err
+	}
+
+	now := time.Now()
+	scheduled = models.ScheduledPublish{
+		ID:        primitive.NewObjectID(),
+		MenuID:    menuID.Hex(),
+		UserID:    userID,
+		Note:      note,
+		PublishAt: at,
+		LocalTime: localTime,
+		Timezone:  MenuLocation(draft).String(),
+		Status:    PublishPending,
+		Menu:      draft,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err = scheduledPublishCollection.InsertOne(ctx, scheduled)
+	return scheduled, err
+}
+
+// ListScheduledPublishes returns the scheduled publishes of a menu, soonest first,
+// without their contents. An empty status returns the pending ones.
+func ListScheduledPublishes(ctx context.Context, menuID string, status string) ([]models.ScheduledPublish, error) {
+	if status == "" {
+		status = PublishPending
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "publishat", Value: 1}}).
+		SetProjection(bson.M{"menu": 0})
+	cursor, err := scheduledPublishCollection.Find(ctx, bson.M{"menuid": menuID, "status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	scheduled := make([]models.ScheduledPublish, 0)
+	err = cursor.All(ctx, &scheduled)
+	return scheduled, err
+}
+
+// FindScheduledPublish loads a scheduled publish by id.
+func FindScheduledPublish(ctx context.Context, id primitive.ObjectID) (models.ScheduledPublish, error) {
+	var scheduled models.ScheduledPublish
+	err := scheduledPublishCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&scheduled)
+	return scheduled, err
+}
+
+// ReschedulePublish moves a pending publish to localTime in the timezone of the
+// scheduled menu.
+func ReschedulePublish(ctx context.Context, scheduled models.ScheduledPublish, localTime string) (models.ScheduledPublish, error) {
+	at, err := scheduleTime(scheduled.Menu, localTime)
+	if err != nil {
+		return scheduled, err
+	}
+	return updateScheduledPublish(ctx, scheduled.ID,
+		bson.M{"publishat": at, "localtime": localTime, "updatedat": time.Now()})
+}
+
+// CancelScheduledPublish stops a pending publish from running.
+func CancelScheduledPublish(ctx context.Context, id primitive.ObjectID) (models.ScheduledPublish, error) {
+	return updateScheduledPublish(ctx, id, bson.M{"status": PublishCancelled, "updatedat": time.Now()})
+}
+
+func updateScheduledPublish(ctx context.Context, id primitive.ObjectID, set bson.M) (models.ScheduledPublish, error) {
+	var scheduled models.ScheduledPublish
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"menu": 0})
+	err := scheduledPublishCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": PublishPending},
+		bson.M{"$set": set}, opts).Decode(&scheduled)
+	if err == mongo.ErrNoDocuments {
+		return scheduled, ErrScheduleClosed
+	}
+	return scheduled, err
+}
+
+// claimScheduledPublish marks one due publish as running and returns it. A claim
+// that has been running for longer than publishClaimTimeout is taken over, so a
+// publish is not lost when the instance running it dies.
+func claimScheduledPublish(ctx context.Context) (models.ScheduledPublish, error) {
+	var scheduled models.ScheduledPublish
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": PublishPending, "publishat": bson.M{"$lte": now}},
+		{"status": PublishRunning, "claimedat": bson.M{"$lte": now.Add(-publishClaimTimeout)}},
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "publishat", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := scheduledPublishCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"status": PublishRunning, "claimedat": now, "updatedat": now}}, opts).Decode(&scheduled)
+	return scheduled, err
+}
+
+// RunScheduledPublishes publishes every scheduled publish that is due. Each one is
+// claimed with a single atomic update and published in the same transaction that
+// marks it done, so several instances may run this at the same time without
+// publishing anything twice.
+func RunScheduledPublishes(ctx context.Context) error {
+	for {
+		scheduled, err := claimScheduledPublish(ctx)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		claim := bson.M{"_id": scheduled.ID, "status": PublishRunning, "claimedat": scheduled.ClaimedAt}
+
+		var version models.MenuVersion
+		err = WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var err error
+			version, err = publishSnapshot(sc, scheduled.Menu, scheduled.UserID, scheduled.Note)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			result, err := scheduledPublishCollection.UpdateOne(sc, claim, bson.M{"$set": bson.M{
+				"status":      PublishDone,
+				"version":     version.Version,
+				"publishedat": now,
+				"updatedat":   now,
+			}})
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errClaimLost
+			}
+			return nil
+		})
+		if err == errClaimLost {
+			continue
+		}
+		if err != nil {
+			message := err.Error()
+			if err == mongo.ErrNoDocuments {
+				message = "Menu not found"
+			}
+			log.Println("scheduled publish", scheduled.ID.Hex(), "failed:", err)
+			if _, err := scheduledPublishCollection.UpdateOne(ctx, claim, bson.M{"$set": bson.M{
+				"status":    PublishFailed,
+				"error":     message,
+				"updatedat": time.Now(),
+			}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		PublishLiveMenuEvent(ctx, "menu", "published", scheduled.Menu.ID, map[string]int{"version": version.Version})
+	}
+}
+
+// StartPublishScheduler runs RunScheduledPublishes every interval until the
+// process exits.
+func StartPublishScheduler(interval time.Duration) {
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := RunScheduledPublishes(ctx); err != nil {
+				log.Println("scheduled publishing failed:", err)
+			}
+			cancel()
+			time.Sleep(interval)
+		}
+	}()
+}
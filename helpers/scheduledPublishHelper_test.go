@@ -0,0 +1,153 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedScheduledMenu stores an empty menu and removes it, its versions and its
+// scheduled publishes when the test ends.
+func seedScheduledMenu(t *testing.T) models.Menu {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	name := func(s string) *string { return &s }
+	menu := models.Menu{
+		ID:           primitive.NewObjectID(),
+		UserID:       name("owner"),
+		Name:         name("Menu"),
+		Logo:         name("logo.png"),
+		Banner:       name("banner.png"),
+		Translations: map[string]models.Translation{},
+		MenuGroup:    []models.MenuGroup{},
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		menuCollection.DeleteOne(ctx, bson.M{"_id": menu.ID})
+		menuVersionCollection.DeleteMany(ctx, bson.M{"menuid": menu.ID.Hex()})
+		scheduledPublishCollection.DeleteMany(ctx, bson.M{"menuid": menu.ID.Hex()})
+	})
+	if _, err := menuCollection.InsertOne(ctx, menu); err != nil {
+		t.Fatal(err)
+	}
+	return menu
+}
+
+// seedScheduledPublish stores a publish of menu that was due a minute ago.
+func seedScheduledPublish(t *testing.T, menu models.Menu, status string, claimedAt *time.Time) primitive.ObjectID {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	scheduled := models.ScheduledPublish{
+		ID:        primitive.NewObjectID(),
+		MenuID:    menu.ID.Hex(),
+		UserID:    "owner",
+		PublishAt: now.Add(-time.Minute),
+		LocalTime: now.Add(-time.Minute).Format(ScheduleLayout),
+		Timezone:  DefaultTimezone,
+		Status:    status,
+		Menu:      menu,
+		ClaimedAt: claimedAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := scheduledPublishCollection.InsertOne(ctx, scheduled); err != nil {
+		t.Fatal(err)
+	}
+	return scheduled.ID
+}
+
+func TestRunScheduledPublishesPublishesEachOnceAcrossInstances(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	menu := seedScheduledMenu(t)
+	const due = 3
+	for i := 0; i < due; i++ {
+		seedScheduledPublish(t, menu, PublishPending, nil)
+	}
+
+	const instances = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, instances)
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- RunScheduledPublishes(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := ListMenuVersions(ctx, menu.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != due {
+		t.Fatalf("%d versions, want %d", len(versions), due)
+	}
+
+	published, err := ListScheduledPublishes(ctx, menu.ID.Hex(), PublishDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numbers := make(map[int]bool)
+	for _, scheduled := range published {
+		numbers[scheduled.Version] = true
+	}
+	if len(published) != due || len(numbers) != due {
+		t.Errorf("%d publishes done with %d distinct versions, want %d of each", len(published), len(numbers), due)
+	}
+}
+
+func TestRunScheduledPublishesTakesOverStaleClaims(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	menu := seedScheduledMenu(t)
+	staleClaim := time.Now().Add(-publishClaimTimeout - time.Minute)
+	freshClaim := time.Now()
+	stale := seedScheduledPublish(t, menu, PublishRunning, &staleClaim)
+	fresh := seedScheduledPublish(t, menu, PublishRunning, &freshClaim)
+
+	if err := RunScheduledPublishes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[primitive.ObjectID]string{stale: PublishDone, fresh: PublishRunning} {
+		scheduled, err := FindScheduledPublish(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scheduled.Status != want {
+			t.Errorf("publish claimed at %v: status = %q, want %q", scheduled.ClaimedAt, scheduled.Status, want)
+		}
+	}
+
+	versions, err := ListMenuVersions(ctx, menu.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("%d versions, want 1", len(versions))
+	}
+}
@@ -0,0 +1,234 @@
+package helper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ServiceWaiter = "waiter"
+	ServiceBill   = "bill"
+
+	ServiceOpen         = "open"
+	ServiceAcknowledged = "acknowledged"
+	ServiceResolved     = "resolved"
+)
+
+var serviceRequestCollection *mongo.Collection = database.OpenCollection(database.Client, "service-request")
+
+var ErrServiceClosed = errors.New("The request has already been handled")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := serviceRequestCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// A table has at most one unresolved call of each type, however many
+			// guests call at the same moment.
+			Keys:    bson.D{{Key: "tableid", Value: 1}, {Key: "type", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{Keys: bson.D{{Key: "tableid", Value: 1}, {Key: "deviceid", Value: 1}, {Key: "createdat", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("could not create service-request indexes:", err)
+	}
+}
+
+// ServiceCallCooldown returns how long a caller has to wait between two calls,
+// based on SERVICE_CALL_COOLDOWN_SECONDS.
+func ServiceCallCooldown() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SERVICE_CALL_COOLDOWN_SECONDS"))
+	if err != nil || seconds < 0 {
+		seconds = 60
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ServiceCallerKey names a caller by the table and the client address, both of
+// which the server determines, so a client cannot start a fresh cooldown by
+// sending a different identifier. It is stored as the request's DeviceID.
+func ServiceCallerKey(tableID string, clientIP string) string {
+	sum := sha256.Sum256([]byte(tableID + "|" + clientIP))
+	return hex.EncodeToString(sum[:])
+}
+
+// ServiceCallWait returns how long the caller has to wait before it may call
+// again from the table, or zero if it may call now.
+func ServiceCallWait(ctx context.Context, tableID string, callerKey string) (time.Duration, error) {
+	cooldown := ServiceCallCooldown()
+	if cooldown == 0 {
+		return 0, nil
+	}
+
+	var last models.ServiceRequest
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdat", Value: -1}})
+	err := serviceRequestCollection.FindOne(ctx, bson.M{"tableid": tableID, "deviceid": callerKey}, opts).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(last.CreatedAt.Add(cooldown))
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// CreateServiceRequest records a call from a table. If the table already has an
+// unresolved call of the same type, that call is returned instead and created is
+// false. The unique index on active calls keeps concurrent calls from both being
+// created.
+func CreateServiceRequest(ctx context.Context, request models.ServiceRequest) (models.ServiceRequest, bool, error) {
+	var existing models.ServiceRequest
+	filter := bson.M{
+		"tableid": request.TableID,
+		"type":    request.Type,
+		"status":  bson.M{"$in": []string{ServiceOpen, ServiceAcknowledged}},
+	}
+	err := serviceRequestCollection.FindOne(ctx, filter).Decode(&existing)
+	if err == nil {
+		return existing, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return request, false, err
+	}
+
+	request.ID = primitive.NewObjectID()
+	request.Status = ServiceOpen
+	request.Active = true
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = time.Now()
+
+	_, err = serviceRequestCollection.InsertOne(ctx, request)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another guest at the table called between the check and the insert.
+		err = serviceRequestCollection.FindOne(ctx, bson.M{"tableid": request.TableID, "type": request.Type, "active": true}).Decode(&existing)
+		return existing, false, err
+	}
+	return request, err == nil, err
+}
+
+// FindServiceRequest loads a call by id.
+func FindServiceRequest(ctx context.Context, id primitive.ObjectID) (models.ServiceRequest, error) {
+	var request models.ServiceRequest
+	err := serviceRequestCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&request)
+	return request, err
+}
+
+// ListServiceRequests returns the calls of a menu, newest first. An empty status
+// returns every unresolved call.
+func ListServiceRequests(ctx context.Context, menuID string, status string) ([]models.ServiceRequest, error) {
+	filter := bson.M{"menuid": menuID}
+	if status == "" {
+		filter["status"] = bson.M{"$in": []string{ServiceOpen, ServiceAcknowledged}}
+	} else {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+	cursor, err := serviceRequestCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := make([]models.ServiceRequest, 0)
+	err = cursor.All(ctx, &requests)
+	return requests, err
+}
+
+// AcknowledgeServiceRequest marks an open call as seen by a staff member.
+func AcknowledgeServiceRequest(ctx context.Context, id primitive.ObjectID, userID string) (models.ServiceRequest, error) {
+	now := time.Now()
+	return updateServiceRequest(ctx,
+		bson.M{"_id": id, "status": ServiceOpen},
+		bson.M{"status": ServiceAcknowledged, "acknowledgedat": now, "acknowledgedby": userID, "updatedat": now})
+}
+
+// ResolveServiceRequest closes an open or acknowledged call.
+func ResolveServiceRequest(ctx context.Context, id primitive.ObjectID, userID string) (models.ServiceRequest, error) {
+	now := time.Now()
+	return updateServiceRequest(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": []string{ServiceOpen, ServiceAcknowledged}}},
+		bson.M{"status": ServiceResolved, "active": false, "resolvedat": now, "resolvedby": userID, "updatedat": now})
+}
+
+func updateServiceRequest(ctx context.Context, filter bson.M, set bson.M) (models.ServiceRequest, error) {
+	var request models.ServiceRequest
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := serviceRequestCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return request, ErrServiceClosed
+	}
+	return request, err
+}
+
+// EscalateServiceRequests flags the calls that have been open for longer than
+// after. Each call is flagged by exactly one update, so several instances may run
+// this at the same time without announcing a call twice.
+func EscalateServiceRequests(ctx context.Context, after time.Duration) error {
+	filter := bson.M{
+		"status":    ServiceOpen,
+		"escalated": false,
+		"createdat": bson.M{"$lte": time.Now().Add(-after)},
+	}
+	cursor, err := serviceRequestCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	var pending []models.ServiceRequest
+	if err := cursor.All(ctx, &pending); err != nil {
+		return err
+	}
+
+	for _, request := range pending {
+		now := time.Now()
+		result, err := serviceRequestCollection.UpdateOne(ctx,
+			bson.M{"_id": request.ID, "status": ServiceOpen, "escalated": false},
+			bson.M{"$set": bson.M{"escalated": true, "escalatedat": now, "updatedat": now}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		request.Escalated = true
+		request.EscalatedAt = &now
+		PublishServiceEvent(ctx, "escalated", request)
+	}
+	return nil
+}
+
+// StartServiceEscalator runs EscalateServiceRequests every interval until the
+// process exits.
+func StartServiceEscalator(after time.Duration, interval time.Duration) {
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := EscalateServiceRequests(ctx, after); err != nil {
+				log.Println("service request escalation failed:", err)
+			}
+			cancel()
+			time.Sleep(interval)
+		}
+	}()
+}
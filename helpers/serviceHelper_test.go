@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateServiceRequestKeepsOneOpenCallPerTable(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tableID := primitive.NewObjectID().Hex()
+	defer serviceRequestCollection.DeleteMany(context.Background(), bson.M{"tableid": tableID})
+	call := models.ServiceRequest{MenuID: primitive.NewObjectID().Hex(), TableID: tableID, Type: ServiceWaiter}
+
+	const callers = 10
+	type result struct {
+		request models.ServiceRequest
+		created bool
+		err     error
+	}
+	results := make(chan result, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			request, created, err := CreateServiceRequest(ctx, call)
+			results <- result{request, created, err}
+		}()
+	}
+
+	created := 0
+	var ids []primitive.ObjectID
+	for i := 0; i < callers; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.created {
+			created++
+		}
+		ids = append(ids, r.request.ID)
+	}
+	if created != 1 {
+		t.Fatalf("%d calls were created, want 1", created)
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("callers got different calls: %v and %v", ids[0], id)
+		}
+	}
+
+	if _, created, err := CreateServiceRequest(ctx, models.ServiceRequest{TableID: tableID, Type: ServiceBill}); err != nil || !created {
+		t.Errorf("bill call: created = %v, err = %v", created, err)
+	}
+
+	if _, err := ResolveServiceRequest(ctx, ids[0], "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, created, err := CreateServiceRequest(ctx, call); err != nil || !created {
+		t.Errorf("call after the first was resolved: created = %v, err = %v", created, err)
+	}
+}
+
+func TestServiceCallerKey(t *testing.T) {
+	key := ServiceCallerKey("table", "203.0.113.1")
+	if key != ServiceCallerKey("table", "203.0.113.1") {
+		t.Error("the key is not stable")
+	}
+	if key == ServiceCallerKey("table", "203.0.113.2") || key == ServiceCallerKey("other", "203.0.113.1") {
+		t.Error("different callers share a key")
+	}
+}
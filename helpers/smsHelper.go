@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSSender sends text messages to phone numbers.
+type SMSSender interface {
+	Send(ctx context.Context, to string, body string) error
+}
+
+var (
+	smsSenderOnce sync.Once
+	smsSender     SMSSender
+	smsSenderErr  error
+)
+
+// OutgoingSMSSender returns the sender chosen by SMS_BACKEND, "log" (the default)
+// or "twilio". It is created on first use, after the environment is loaded.
+func OutgoingSMSSender() (SMSSender, error) {
+	smsSenderOnce.Do(func() {
+		switch os.Getenv("SMS_BACKEND") {
+		case "", "log":
+			smsSender = LogSMSSender{}
+		case "twilio":
+			smsSender, smsSenderErr = newTwilioSMSSender()
+		default:
+			smsSenderErr = fmt.Errorf("unknown SMS_BACKEND %q", os.Getenv("SMS_BACKEND"))
+		}
+	})
+	return smsSender, smsSenderErr
+}
+
+// LogSMSSender writes text messages to the server log instead of sending them. It
+// is meant for development.
+type LogSMSSender struct{}
+
+func (LogSMSSender) Send(ctx context.Context, to string, body string) error {
+	log.Printf("sms to %s: %s", to, body)
+	return nil
+}
+
+// TwilioSMSSender sends text messages through the Twilio Messages API.
+type TwilioSMSSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+	Client     *http.Client
+}
+
+func newTwilioSMSSender() (*TwilioSMSSender, error) {
+	s := &TwilioSMSSender{
+		AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+		AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+		From:       os.Getenv("SMS_FROM"),
+		BaseURL:    "https://api.twilio.com",
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	if s.AccountSID == "" || s.AuthToken == "" || s.From == "" {
+		return nil, fmt.Errorf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and SMS_FROM are required")
+	}
+	return s, nil
+}
+
+func (s *TwilioSMSSender) Send(ctx context.Context, to string, body string) error {
+	form := url.Values{"To": {to}, "From": {s.From}, "Body": {body}}
+	endpoint := s.BaseURL + "/2010-04-01/Accounts/" + s.AccountSID + "/Messages.json"
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("sms to %s: %s %s", to, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrOutOfStock = errors.New("Not enough stock left")
+
+// SetStock updates the sold-out state and stock of an item without touching its
+// other fields. A stock of zero marks the item sold out; untrack removes the stock
+// count so that only the sold-out flag applies. A sold-out flag given by staff
+// stays until staff lift it.
+func SetStock(ctx context.Context, itemID primitive.ObjectID, soldOut *bool, stock *int64, untrack bool) error {
+	set := bson.M{"updatedat": time.Now()}
+	update := bson.M{}
+
+	if untrack {
+		update["$unset"] = bson.M{"stock": ""}
+	} else if stock != nil {
+		set["stock"] = *stock
+		set["soldout"] = *stock == 0
+		set["soldoutbystock"] = *stock == 0
+	}
+	if soldOut != nil {
+		set["soldout"] = *soldOut
+		set["soldoutbystock"] = false
+	}
+	update["$set"] = set
+
+	result, err := menuItemCollection.UpdateOne(ctx, bson.M{"_id": itemID, "deleted_at": nil}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// TakeStock atomically removes quantity from a tracked stock and marks the item
+// sold out once nothing is left. Items without a stock count are not limited.
+func TakeStock(ctx context.Context, itemID primitive.ObjectID, quantity int64) error {
+	result, err := menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID, "stock": bson.M{"$gte": quantity}},
+		bson.M{"$inc": bson.M{"stock": -quantity}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		// Either the stock is too low or the item does not track one.
+		untracked, err := menuItemCollection.CountDocuments(ctx, bson.M{"_id": itemID, "stock": nil})
+		if err != nil {
+			return err
+		}
+		if untracked > 0 {
+			return nil
+		}
+		return ErrOutOfStock
+	}
+
+	_, err = menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID, "stock": bson.M{"$lte": 0}, "soldout": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"soldout": true, "soldoutbystock": true}})
+	return err
+}
+
+// RestoreStock gives quantity back to a tracked stock, for example when an order
+// is cancelled. An item that was marked sold out because its stock ran out is
+// available again, one that staff marked sold out is not; items without a stock
+// count are left alone.
+func RestoreStock(ctx context.Context, itemID primitive.ObjectID, quantity int64) error {
+	result, err := menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID, "stock": bson.M{"$lte": 0}, "soldoutbystock": true},
+		bson.M{"$inc": bson.M{"stock": quantity}, "$set": bson.M{"soldout": false, "soldoutbystock": false}})
+	if err != nil || result.ModifiedCount > 0 {
+		return err
+	}
+
+	_, err = menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID, "stock": bson.M{"$ne": nil}},
+		bson.M{"$inc": bson.M{"stock": quantity}})
+	return err
+}
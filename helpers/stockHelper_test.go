@@ -0,0 +1,155 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTakeStockNeverOversells(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	itemID := primitive.NewObjectID()
+	if _, err := menuItemCollection.InsertOne(ctx, bson.M{"_id": itemID, "stock": int64(5), "soldout": false}); err != nil {
+		t.Fatal(err)
+	}
+	defer menuItemCollection.DeleteOne(context.Background(), bson.M{"_id": itemID})
+
+	const buyers = 10
+	errs := make(chan error, buyers)
+	for i := 0; i < buyers; i++ {
+		go func() { errs <- TakeStock(ctx, itemID, 1) }()
+	}
+	sold := 0
+	for i := 0; i < buyers; i++ {
+		switch err := <-errs; err {
+		case nil:
+			sold++
+		case ErrOutOfStock:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if sold != 5 {
+		t.Errorf("sold %d, want 5", sold)
+	}
+
+	var item struct {
+		Stock   int64
+		SoldOut bool
+	}
+	if err := menuItemCollection.FindOne(ctx, bson.M{"_id": itemID}).Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Stock != 0 || !item.SoldOut {
+		t.Errorf("stock = %d, sold out = %v, want 0 and sold out", item.Stock, item.SoldOut)
+	}
+}
+
+func TestTakeStockIgnoresUntrackedItems(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	itemID := primitive.NewObjectID()
+	if _, err := menuItemCollection.InsertOne(ctx, bson.M{"_id": itemID}); err != nil {
+		t.Fatal(err)
+	}
+	defer menuItemCollection.DeleteOne(context.Background(), bson.M{"_id": itemID})
+
+	if err := TakeStock(ctx, itemID, 3); err != nil {
+		t.Errorf("untracked item: %v", err)
+	}
+}
+
+func TestRestoreStock(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ranOut := primitive.NewObjectID()
+	markedByStaff := primitive.NewObjectID()
+	inStock := primitive.NewObjectID()
+	untracked := primitive.NewObjectID()
+	ids := []primitive.ObjectID{ranOut, markedByStaff, inStock, untracked}
+	_, err := menuItemCollection.InsertMany(ctx, []interface{}{
+		bson.M{"_id": ranOut, "stock": int64(0), "soldout": true, "soldoutbystock": true},
+		bson.M{"_id": markedByStaff, "stock": int64(0), "soldout": true, "soldoutbystock": false},
+		bson.M{"_id": inStock, "stock": int64(4), "soldout": false},
+		bson.M{"_id": untracked, "soldout": false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menuItemCollection.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+
+	for _, id := range ids {
+		if err := RestoreStock(ctx, id, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id      primitive.ObjectID
+		stock   *int64
+		soldOut bool
+	}{
+		{ranOut, int64Ptr(2), false},
+		{markedByStaff, int64Ptr(2), true},
+		{inStock, int64Ptr(6), false},
+		{untracked, nil, false},
+	}
+	for _, tt := range tests {
+		var item struct {
+			Stock   *int64
+			SoldOut bool
+		}
+		if err := menuItemCollection.FindOne(ctx, bson.M{"_id": tt.id}).Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		if (item.Stock == nil) != (tt.stock == nil) || (item.Stock != nil && *item.Stock != *tt.stock) || item.SoldOut != tt.soldOut {
+			t.Errorf("%s: stock = %v, sold out = %v", tt.id.Hex(), item.Stock, item.SoldOut)
+		}
+	}
+}
+
+func TestSetStockTouchesTheItemTimestamp(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	itemID := primitive.NewObjectID()
+	updatedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	if _, err := menuItemCollection.InsertOne(ctx, bson.M{"_id": itemID, "stock": int64(3), "updatedat": updatedAt}); err != nil {
+		t.Fatal(err)
+	}
+	defer menuItemCollection.DeleteOne(context.Background(), bson.M{"_id": itemID})
+
+	if err := SetStock(ctx, itemID, nil, int64Ptr(0), false); err != nil {
+		t.Fatal(err)
+	}
+
+	var item models.MenuItem
+	if err := menuItemCollection.FindOne(ctx, bson.M{"_id": itemID}).Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	if !item.UpdatedAt.After(updatedAt) {
+		t.Errorf("updatedat = %v, want it moved on from %v", item.UpdatedAt, updatedAt)
+	}
+	if !item.SoldOut || !item.SoldOutByStock {
+		t.Errorf("sold out = %v, by stock = %v, want both", item.SoldOut, item.SoldOutByStock)
+	}
+	if n, _ := menuItemCollection.CountDocuments(ctx, bson.M{"_id": itemID, "updated_at": bson.M{"$exists": true}}); n != 0 {
+		t.Error("SetStock wrote a stray updated_at field")
+	}
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
@@ -0,0 +1,83 @@
+package helper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+
+var ErrInvalidTableToken = errors.New("The table code is invalid")
+
+var ErrTableNumberTaken = errors.New("Another table of the menu has this number")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A number belongs to one table of a menu. Tables without a number (0) are
+	// not counted.
+	_, err := tableCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "menuid", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"number": bson.M{"$gt": 0}}),
+	})
+	if err != nil {
+		log.Println("could not create table indexes:", err)
+	}
+}
+
+func tableSignature(tableID string, menuID string) string {
+	mac := hmac.New(sha256.New, []byte(SECRET_KEY))
+	mac.Write([]byte("table:" + tableID + ":" + menuID))
+	// A truncated signature keeps the token, and therefore the QR code, small.
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// TableToken returns the signed token that identifies a table in its QR code.
+func TableToken(table models.Table) string {
+	return table.ID.Hex() + "." + tableSignature(table.ID.Hex(), *table.MenuID)
+}
+
+// TableURL returns the public menu address for guests sitting at the table.
+func TableURL(table models.Table) string {
+	return MenuURL(*table.MenuID) + "?table=" + TableToken(table)
+}
+
+// ResolveTableToken verifies a table token and returns the table, which must
+// belong to the given menu.
+func ResolveTableToken(ctx context.Context, token string, menuID string) (models.Table, error) {
+	var table models.Table
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return table, ErrInvalidTableToken
+	}
+
+	tableID, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return table, ErrInvalidTableToken
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(tableSignature(parts[0], menuID))) {
+		return table, ErrInvalidTableToken
+	}
+
+	err = tableCollection.FindOne(ctx, bson.M{"_id": tableID, "menuid": menuID}).Decode(&table)
+	if err == mongo.ErrNoDocuments {
+		return table, ErrInvalidTableToken
+	}
+	return table, err
+}
@@ -9,6 +9,7 @@ import (
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,34 +22,95 @@ type SignedDetails struct {
 	Last_name  string
 	Uid        string
 	User_type  string
+	Token_type string
+	Family     string
+	// Issued_at_ms is the issue time in milliseconds; IssuedAt only has seconds,
+	// which is too coarse to tell a token from a revocation in the same second.
+	Issued_at_ms int64
 	jwt.StandardClaims
 }
 
+const (
+	AccessToken    = "access"
+	RefreshToken   = "refresh"
+	ChallengeToken = "challenge"
+	StreamToken    = "stream"
+)
+
+const (
+	AccessTokenTTL    = 24 * time.Hour
+	RefreshTokenTTL   = 168 * time.Hour
+	ChallengeTokenTTL = 5 * time.Minute
+	StreamTokenTTL    = 10 * time.Minute
+)
+
 var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+var sessionCollection *mongo.Collection = database.OpenCollection(database.Client, "session")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := sessionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.M{"family": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.M{"userid": 1}},
+	})
+	if err != nil {
+		log.Println("could not create session indexes:", err)
+	}
+}
 
 var SECRET_KEY string = os.Getenv("SECRET_KEY")
 
+// GenerateAllTokens issues an access/refresh pair that starts a new refresh token family.
 func GenerateAllTokens(email string, firstName string, lastName string, userType string, uid string) (signedToken string, signedRefreshToken string, err error) {
+	return GenerateFamilyTokens(email, firstName, lastName, userType, uid, primitive.NewObjectID().Hex())
+}
+
+// GenerateFamilyTokens issues an access/refresh pair whose refresh token belongs to the given family.
+func GenerateFamilyTokens(email string, firstName string, lastName string, userType string, uid string, family string) (signedToken string, signedRefreshToken string, err error) {
+	now := time.Now()
 	claims := &SignedDetails{
-		Email:      email,
-		First_name: firstName,
-		Last_name:  lastName,
-		Uid:        uid,
-		User_type:  userType,
+		Email:        email,
+		First_name:   firstName,
+		Last_name:    lastName,
+		Uid:          uid,
+		User_type:    userType,
+		Token_type:   AccessToken,
+		Family:       family,
+		Issued_at_ms: unixMillis(now),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: time.Now().Local().Add(AccessTokenTTL).Unix(),
 		},
 	}
 
 	refreshClaims := &SignedDetails{
+		Uid:          uid,
+		Token_type:   RefreshToken,
+		Family:       family,
+		Issued_at_ms: unixMillis(now),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: time.Now().Local().Add(RefreshTokenTTL).Unix(),
 		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		log.Panic(err)
+		return
+	}
 	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
-
 	if err != nil {
 		log.Panic(err)
 		return
@@ -57,7 +119,64 @@ func GenerateAllTokens(email string, firstName string, lastName string, userType
 	return token, refreshToken, err
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+// GenerateChallengeToken issues the token that proves the password step of a
+// two-factor login. It is only accepted by ValidateChallengeToken.
+func GenerateChallengeToken(uid string) (string, error) {
+	now := time.Now()
+	claims := &SignedDetails{
+		Uid:          uid,
+		Token_type:   ChallengeToken,
+		Issued_at_ms: unixMillis(now),
+		StandardClaims: jwt.StandardClaims{
+			Id:        primitive.NewObjectID().Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: time.Now().Local().Add(ChallengeTokenTTL).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+// GenerateStreamToken issues the token that opens the event stream of a signed in
+// user. Browsers have to send it in the address, where it may end up in logs, so
+// it is only accepted by ValidateStreamToken and expires after StreamTokenTTL, or
+// with the access token it was issued for. It carries that access token's id and
+// family, so logging out revokes it too.
+func GenerateStreamToken(uid string, userType string, family string, accessID string, accessExpiresAt int64) (string, int64, error) {
+	now := time.Now()
+	expiresAt := now.Add(StreamTokenTTL).Unix()
+	if accessExpiresAt < expiresAt {
+		expiresAt = accessExpiresAt
+	}
+	claims := &SignedDetails{
+		Uid:          uid,
+		User_type:    userType,
+		Token_type:   StreamToken,
+		Family:       family,
+		Issued_at_ms: unixMillis(now),
+		StandardClaims: jwt.StandardClaims{
+			Id:        accessID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt,
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	return token, expiresAt, err
+}
+
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// IssuedTime returns when the token was issued, to the millisecond for tokens that
+// carry Issued_at_ms and to the second for older ones.
+func (claims *SignedDetails) IssuedTime() time.Time {
+	if claims.Issued_at_ms != 0 {
+		return time.Unix(0, claims.Issued_at_ms*int64(time.Millisecond))
+	}
+	return time.Unix(claims.IssuedAt, 0)
+}
+
+func parseToken(signedToken string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
@@ -74,18 +193,80 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	claims, ok := token.Claims.(*SignedDetails)
 	if !ok {
 		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
 		return
 	}
 
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		msg = fmt.Sprintf("token is expired")
-		msg = err.Error()
 		return
 	}
 	return claims, msg
 }
 
+func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedToken)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if claims.Token_type == RefreshToken {
+		return nil, fmt.Sprintf("refresh token cannot be used as an access token")
+	}
+	if claims.Token_type == ChallengeToken {
+		return nil, fmt.Sprintf("challenge token cannot be used as an access token")
+	}
+	if claims.Token_type == StreamToken {
+		return nil, fmt.Sprintf("stream token cannot be used as an access token")
+	}
+	return claims, msg
+}
+
+func ValidateChallengeToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedToken)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if claims.Token_type != ChallengeToken || claims.Uid == "" {
+		return nil, fmt.Sprintf("the challenge token is invalid")
+	}
+	return claims, msg
+}
+
+func ValidateStreamToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedToken)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if claims.Token_type != StreamToken || claims.Uid == "" {
+		return nil, fmt.Sprintf("the stream token is invalid")
+	}
+	return claims, msg
+}
+
+func ValidateRefreshToken(signedRefreshToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedRefreshToken)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if claims.Token_type != RefreshToken || claims.Uid == "" {
+		return nil, fmt.Sprintf("the refresh token is invalid")
+	}
+	return claims, msg
+}
+
+// TokenFamily returns the family of a refresh token that was issued by this server,
+// without checking its signature or expiry.
+func TokenFamily(signedRefreshToken string) string {
+	claims := &SignedDetails{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(signedRefreshToken, claims); err != nil {
+		return ""
+	}
+	return claims.Family
+}
+
 func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 
@@ -118,5 +299,124 @@ func UpdateAllTokens(signedToken string, signedRefreshToken string, userId strin
 		log.Panic(err)
 		return
 	}
+
+	if err := StartSession(ctx, userId, signedRefreshToken); err != nil {
+		log.Panic(err)
+	}
 	return
 }
+
+// StartSession stores the refresh token of a new login as the current token of its
+// family. A refresh token without a session cannot be exchanged.
+func StartSession(ctx context.Context, userId string, signedRefreshToken string) error {
+	now := time.Now()
+	session := models.Session{
+		ID:           primitive.NewObjectID(),
+		UserID:       userId,
+		Family:       TokenFamily(signedRefreshToken),
+		RefreshToken: signedRefreshToken,
+		ExpiresAt:    now.Add(RefreshTokenTTL),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	_, err := sessionCollection.InsertOne(ctx, session)
+	return err
+}
+
+// RotateTokens replaces the refresh token of its session only if it still equals
+// oldRefreshToken, so a refresh token can be exchanged exactly once. Every login
+// is its own session, so refreshing on one device does not affect the others.
+func RotateTokens(signedToken string, signedRefreshToken string, oldRefreshToken string, userId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"userid":       userId,
+		"family":       TokenFamily(oldRefreshToken),
+		"refreshtoken": oldRefreshToken,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"refreshtoken": signedRefreshToken,
+			"expiresat":    now.Add(RefreshTokenTTL),
+			"updatedat":    now,
+		},
+	}
+
+	result, err := sessionCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount != 1 {
+		return false, nil
+	}
+
+	// The user keeps the latest tokens issued to any of its sessions.
+	Updated_at, _ := time.Parse(time.RFC3339, now.Format(time.RFC3339))
+	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{
+		"$set": bson.M{
+			"token":         signedToken,
+			"refresh_token": signedRefreshToken,
+			"updated_at":    Updated_at,
+		},
+	})
+	return err == nil, err
+}
+
+// RevokeTokenFamily ends the session of the given refresh token family, so none of
+// its refresh tokens can be exchanged any more.
+func RevokeTokenFamily(userId string, family string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if _, err := sessionCollection.DeleteOne(ctx, bson.M{"userid": userId, "family": family}); err != nil {
+		return err
+	}
+
+	// Forget the tokens stored on the user if they belong to the ended session.
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Refresh_token == nil || TokenFamily(*user.Refresh_token) != family {
+		return nil
+	}
+
+	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userId, "refresh_token": *user.Refresh_token}, bson.M{
+		"$set": bson.M{
+			"token":         nil,
+			"refresh_token": nil,
+			"updated_at":    Updated_at,
+		},
+	})
+	return err
+}
+
+// ClearTokens removes the stored access and refresh token of a user and ends all
+// of the user's sessions.
+func ClearTokens(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.M{
+		"$set": bson.M{
+			"token":         nil,
+			"refresh_token": nil,
+			"updated_at":    Updated_at,
+		},
+	}
+
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, update); err != nil {
+		return err
+	}
+
+	_, err := sessionCollection.DeleteMany(ctx, bson.M{"userid": userId})
+	return err
+}
@@ -0,0 +1,128 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSessionsRotateTheirOwnRefreshTokens(t *testing.T) {
+	requireDatabase(t)
+	user := seedUser(t, models.User{})
+	defer sessionCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+
+	login := func() (string, string) {
+		token, refreshToken, err := GenerateAllTokens(*user.Email, "", "", "USER", user.User_id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		UpdateAllTokens(token, refreshToken, user.User_id)
+		return refreshToken, TokenFamily(refreshToken)
+	}
+	rotate := func(oldRefreshToken string, family string) (string, bool) {
+		token, refreshToken, err := GenerateFamilyTokens(*user.Email, "", "", "USER", user.User_id, family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated, err := RotateTokens(token, refreshToken, oldRefreshToken, user.User_id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return refreshToken, rotated
+	}
+
+	phone, phoneFamily := login()
+	laptop, laptopFamily := login()
+
+	newPhone, rotated := rotate(phone, phoneFamily)
+	if !rotated {
+		t.Fatal("the phone could not refresh")
+	}
+	if _, rotated := rotate(laptop, laptopFamily); !rotated {
+		t.Fatal("the laptop could not refresh after the phone did")
+	}
+	if _, rotated := rotate(phone, phoneFamily); rotated {
+		t.Fatal("a used refresh token was exchanged again")
+	}
+
+	if err := RevokeTokenFamily(user.User_id, laptopFamily); err != nil {
+		t.Fatal(err)
+	}
+	if _, rotated := rotate(newPhone, phoneFamily); !rotated {
+		t.Error("ending the laptop session also ended the phone session")
+	}
+
+	if err := ClearTokens(user.User_id); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := sessionCollection.CountDocuments(context.Background(), bson.M{"userid": user.User_id}); n != 0 {
+		t.Errorf("%d sessions left after clearing the tokens", n)
+	}
+}
+
+func TestStreamTokensOnlyOpenTheStream(t *testing.T) {
+	access, _, err := GenerateAllTokens("a@example.com", "A", "B", "USER", "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessClaims, msg := ValidateToken(access)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+
+	stream, expiresAt, err := GenerateStreamToken(accessClaims.Uid, accessClaims.User_type, accessClaims.Family, accessClaims.Id, accessClaims.ExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, msg := ValidateToken(stream); msg == "" {
+		t.Error("a stream token was accepted as an access token")
+	}
+	if _, msg := ValidateStreamToken(access); msg == "" {
+		t.Error("an access token was accepted as a stream token")
+	}
+	claims, msg := ValidateStreamToken(stream)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if claims.Uid != "user-1" || claims.Id != accessClaims.Id || claims.Family != accessClaims.Family {
+		t.Errorf("stream claims = %+v, want the user, id and family of the access token", claims)
+	}
+	if limit := time.Now().Add(StreamTokenTTL).Unix(); expiresAt > limit || claims.ExpiresAt != expiresAt {
+		t.Errorf("stream token expires at %d, want at most %d", claims.ExpiresAt, limit)
+	}
+
+	// A stream token never outlives the access token it was issued for.
+	soon := time.Now().Add(time.Minute).Unix()
+	if _, expiresAt, _ := GenerateStreamToken("user-1", "USER", "", accessClaims.Id, soon); expiresAt != soon {
+		t.Errorf("stream token expires at %d, want the access token's %d", expiresAt, soon)
+	}
+}
+
+func TestRevokingTheAccessTokenRevokesItsStreamToken(t *testing.T) {
+	requireDatabase(t)
+	user := seedUser(t, models.User{})
+
+	access, _, err := GenerateAllTokens(*user.Email, "A", "B", "USER", user.User_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessClaims, _ := ValidateToken(access)
+	stream, _, err := GenerateStreamToken(user.User_id, "USER", accessClaims.Family, accessClaims.Id, accessClaims.ExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := ValidateStreamToken(stream)
+
+	if revoked, err := IsTokenRevoked(claims); err != nil || revoked {
+		t.Fatalf("fresh stream token: revoked = %v, err = %v", revoked, err)
+	}
+	if err := RevokeToken(accessClaims.Id, user.User_id, accessClaims.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := IsTokenRevoked(claims); err != nil || !revoked {
+		t.Errorf("after logging out: revoked = %v, err = %v, want revoked", revoked, err)
+	}
+}
@@ -0,0 +1,244 @@
+package helper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TOTP parameters of RFC 6238 that authenticator apps use by default.
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods before and after the current one are accepted,
+	// to allow for clock drift between the server and the phone.
+	totpSkew = 1
+)
+
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	ErrInvalidTOTPCode    = errors.New("The authentication code is invalid")
+	ErrTOTPNotEnabled     = errors.New("Two-factor authentication is not enabled")
+	ErrTOTPAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrTOTPNotSetUp       = errors.New("Two-factor authentication has not been set up")
+)
+
+// TOTPCode returns the code of the secret for the given time step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000), nil
+}
+
+// matchTOTP returns the time step the code belongs to, if it is valid around now.
+func matchTOTP(secret string, code string, now time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth:// address that authenticator apps read
+// from a QR code. The issuer is taken from TOTP_ISSUER.
+func TOTPProvisioningURI(secret string, account string) string {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "QR Menu"
+	}
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// normalizeRecoveryCode lets recovery codes be typed with any case, spaces or
+// dashes.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes returns new one-time recovery codes and the hashes they
+// are stored as.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 7)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(random))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// StartTOTPSetup creates a new secret for the user. It only takes effect once
+// EnableTOTP confirms that the user's app produces the right codes.
+func StartTOTPSetup(ctx context.Context, user models.User) (string, error) {
+	if user.Totp_enabled {
+		return "", ErrTOTPAlreadyEnabled
+	}
+
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	secret := totpEncoding.EncodeToString(random)
+
+	_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id},
+		bson.M{"$set": bson.M{"totp_pending": secret, "updated_at": time.Now()}})
+	return secret, err
+}
+
+// EnableTOTP turns two-factor authentication on when the code matches the secret
+// from StartTOTPSetup, and returns the user's recovery codes.
+func EnableTOTP(ctx context.Context, user models.User, code string) ([]string, error) {
+	if user.Totp_enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.Totp_pending == nil {
+		return nil, ErrTOTPNotSetUp
+	}
+	step, valid := matchTOTP(*user.Totp_pending, code, time.Now())
+	if !valid {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": user.User_id, "totp_enabled": bson.M{"$ne": true}, "totp_pending": *user.Totp_pending},
+		bson.M{
+			"$set": bson.M{
+				"totp_enabled":   true,
+				"totp_secret":    *user.Totp_pending,
+				"totp_last_step": step,
+				"recovery_codes": hashes,
+				"updated_at":     time.Now(),
+			},
+			"$unset": bson.M{"totp_pending": ""},
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrTOTPNotSetUp
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor checks a code from the user's app or one of their recovery
+// codes. Each code is accepted only once: an app code is rejected if a code of
+// the same or a later time step was used before, and a recovery code is removed.
+func VerifySecondFactor(ctx context.Context, user models.User, code string) error {
+	if !user.Totp_enabled || user.Totp_secret == nil {
+		return ErrTOTPNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, valid := matchTOTP(*user.Totp_secret, code, time.Now()); valid {
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.User_id, "totp_last_step": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"totp_last_step": step}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	}
+
+	hash := hashRecoveryCode(code)
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": user.User_id, "recovery_codes": hash},
+		bson.M{"$pull": bson.M{"recovery_codes": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a code.
+func DisableTOTP(ctx context.Context, user models.User, code string) error {
+	if err := VerifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+	_, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, bson.M{
+		"$set":   bson.M{"totp_enabled": false, "updated_at": time.Now()},
+		"$unset": bson.M{"totp_secret": "", "totp_pending": "", "totp_last_step": "", "recovery_codes": ""},
+	})
+	return err
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// code, and returns the new ones.
+func RegenerateRecoveryCodes(ctx context.Context, user models.User, code string) ([]string, error) {
+	if err := VerifySecondFactor(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id},
+		bson.M{"$set": bson.M{"recovery_codes": hashes, "updated_at": time.Now()}})
+	return codes, err
+}
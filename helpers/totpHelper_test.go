@@ -0,0 +1,114 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 appendix B, "12345678901234567890",
+// in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA-1 test vectors of RFC 6238 appendix B. The RFC lists eight digit codes;
+// six digit codes are their last six digits.
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		code, err := TOTPCode(rfc6238Secret, test.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("T=%d: code = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestMatchTOTPAllowsOneStepOfDrift(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, _ := TOTPCode(rfc6238Secret, current+offset)
+		step, valid := matchTOTP(rfc6238Secret, code, now)
+		wantValid := offset >= -totpSkew && offset <= totpSkew
+		if valid != wantValid {
+			t.Errorf("offset %d: valid = %v, want %v", offset, valid, wantValid)
+		}
+		if valid && step != current+offset {
+			t.Errorf("offset %d: step = %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	if _, valid := matchTOTP(rfc6238Secret, "12345", now); valid {
+		t.Error("a five digit code was accepted")
+	}
+}
+
+func TestVerifySecondFactorRejectsReplays(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	secret := rfc6238Secret
+	_, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := seedUser(t, models.User{Totp_enabled: true, Totp_secret: &secret, Recovery_codes: hashes})
+
+	current := time.Now().Unix() / totpPeriod
+	code, _ := TOTPCode(secret, current)
+	if err := VerifySecondFactor(ctx, user, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := VerifySecondFactor(ctx, user, code); err != ErrInvalidTOTPCode {
+		t.Errorf("same code again: err = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+
+	earlier, _ := TOTPCode(secret, current-1)
+	if err := VerifySecondFactor(ctx, user, earlier); err != ErrInvalidTOTPCode {
+		t.Errorf("code of an earlier step: err = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+
+	later, _ := TOTPCode(secret, current+1)
+	if err := VerifySecondFactor(ctx, user, later); err != nil {
+		t.Errorf("code of the next step: %v", err)
+	}
+}
+
+func TestVerifySecondFactorUsesRecoveryCodesOnce(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	secret := rfc6238Secret
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := seedUser(t, models.User{Totp_enabled: true, Totp_secret: &secret, Recovery_codes: hashes})
+
+	if err := VerifySecondFactor(ctx, user, codes[0]); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := VerifySecondFactor(ctx, user, codes[0]); err != ErrInvalidTOTPCode {
+		t.Errorf("second use: err = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+	if err := VerifySecondFactor(ctx, user, codes[1]); err != nil {
+		t.Errorf("another recovery code: %v", err)
+	}
+}
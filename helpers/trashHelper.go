@@ -0,0 +1,346 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrParentDeleted = errors.New("Parent is in the trash, restore it first")
+
+// TrashMenu moves a menu with its groups and items to the trash in one transaction.
+// Children get the same deleted_at as the menu, so RestoreMenu can tell them apart
+// from children that were trashed on their own before. It returns
+// mongo.ErrNoDocuments if the menu does not exist or is already in the trash.
+func TrashMenu(ctx context.Context, menuID primitive.ObjectID) error {
+	deletedAt := time.Now()
+
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := menuCollection.UpdateOne(sc,
+			bson.M{"_id": menuID, "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		groupIDs, err := findGroupIDs(sc, bson.M{"menuid": menuID.Hex(), "deleted_at": nil})
+		if err != nil {
+			return err
+		}
+
+		if _, err := menuItemCollection.UpdateMany(sc,
+			bson.M{"groupid": bson.M{"$in": groupIDs}, "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": deletedAt}}); err != nil {
+			return err
+		}
+		_, err = menuGroupCollection.UpdateMany(sc,
+			bson.M{"menuid": menuID.Hex(), "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+		return err
+	})
+}
+
+// TrashGroup moves a group with its items to the trash in one transaction.
+func TrashGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	deletedAt := time.Now()
+
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := menuGroupCollection.UpdateOne(sc,
+			bson.M{"_id": groupID, "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		_, err = menuItemCollection.UpdateMany(sc,
+			bson.M{"groupid": groupID.Hex(), "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+		return err
+	})
+}
+
+func TrashItem(ctx context.Context, itemID primitive.ObjectID) error {
+	result, err := menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RestoreMenu takes a menu out of the trash along with the groups and items that
+// were trashed together with it.
+func RestoreMenu(ctx context.Context, menuID primitive.ObjectID) error {
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var menu models.Menu
+		err := menuCollection.FindOne(sc, bson.M{"_id": menuID, "deleted_at": bson.M{"$ne": nil}}).Decode(&menu)
+		if err != nil {
+			return err
+		}
+
+		if _, err := menuCollection.UpdateOne(sc,
+			bson.M{"_id": menuID},
+			bson.M{"$unset": bson.M{"deleted_at": ""}}); err != nil {
+			return err
+		}
+
+		groupIDs, err := findGroupIDs(sc, bson.M{"menuid": menuID.Hex()})
+		if err != nil {
+			return err
+		}
+
+		if _, err := menuItemCollection.UpdateMany(sc,
+			bson.M{"groupid": bson.M{"$in": groupIDs}, "deleted_at": menu.DeletedAt},
+			bson.M{"$unset": bson.M{"deleted_at": ""}}); err != nil {
+			return err
+		}
+		_, err = menuGroupCollection.UpdateMany(sc,
+			bson.M{"menuid": menuID.Hex(), "deleted_at": menu.DeletedAt},
+			bson.M{"$unset": bson.M{"deleted_at": ""}})
+		return err
+	})
+}
+
+// RestoreGroup takes a group out of the trash along with the items that were
+// trashed together with it. The group's menu must not be in the trash.
+func RestoreGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var group models.MenuGroup
+		err := menuGroupCollection.FindOne(sc, bson.M{"_id": groupID, "deleted_at": bson.M{"$ne": nil}}).Decode(&group)
+		if err != nil {
+			return err
+		}
+
+		if group.MenuID == nil {
+			return ErrParentDeleted
+		}
+		menuID, err := primitive.ObjectIDFromHex(*group.MenuID)
+		if err != nil {
+			return ErrParentDeleted
+		}
+		count, err := menuCollection.CountDocuments(sc, bson.M{"_id": menuID, "deleted_at": nil})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrParentDeleted
+		}
+
+		if _, err := menuGroupCollection.UpdateOne(sc,
+			bson.M{"_id": groupID},
+			bson.M{"$unset": bson.M{"deleted_at": ""}}); err != nil {
+			return err
+		}
+		_, err = menuItemCollection.UpdateMany(sc,
+			bson.M{"groupid": groupID.Hex(), "deleted_at": group.DeletedAt},
+			bson.M{"$unset": bson.M{"deleted_at": ""}})
+		return err
+	})
+}
+
+// RestoreItem takes an item out of the trash. The item's group must not be in the trash.
+func RestoreItem(ctx context.Context, itemID primitive.ObjectID) error {
+	var item models.MenuItem
+	err := menuItemCollection.FindOne(ctx, bson.M{"_id": itemID, "deleted_at": bson.M{"$ne": nil}}).Decode(&item)
+	if err != nil {
+		return err
+	}
+
+	if item.GroupID == nil {
+		return ErrParentDeleted
+	}
+	groupID, err := primitive.ObjectIDFromHex(*item.GroupID)
+	if err != nil {
+		return ErrParentDeleted
+	}
+	count, err := menuGroupCollection.CountDocuments(ctx, bson.M{"_id": groupID, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrParentDeleted
+	}
+
+	_, err = menuItemCollection.UpdateOne(ctx,
+		bson.M{"_id": itemID},
+		bson.M{"$unset": bson.M{"deleted_at": ""}})
+	return err
+}
+
+// Trash holds the trashed records of a user's menus.
+type Trash struct {
+	Menus  []models.Menu      `json:"menus"`
+	Groups []models.MenuGroup `json:"groups"`
+	Items  []models.MenuItem  `json:"items"`
+}
+
+func ListTrash(ctx context.Context, userID string) (Trash, error) {
+	trash := Trash{
+		Menus:  make([]models.Menu, 0),
+		Groups: make([]models.MenuGroup, 0),
+		Items:  make([]models.MenuItem, 0),
+	}
+
+	menuIDs := make([]string, 0)
+	cursor, err := menuCollection.Find(ctx, bson.M{"userid": userID})
+	if err != nil {
+		return trash, err
+	}
+	var menus []models.Menu
+	if err := cursor.All(ctx, &menus); err != nil {
+		return trash, err
+	}
+	for _, menu := range menus {
+		menuIDs = append(menuIDs, menu.ID.Hex())
+		if menu.DeletedAt != nil {
+			trash.Menus = append(trash.Menus, menu)
+		}
+	}
+
+	cursor, err = menuGroupCollection.Find(ctx, bson.M{"menuid": bson.M{"$in": menuIDs}})
+	if err != nil {
+		return trash, err
+	}
+	var groups []models.MenuGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		return trash, err
+	}
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID.Hex())
+		if group.DeletedAt != nil {
+			trash.Groups = append(trash.Groups, group)
+		}
+	}
+
+	cursor, err = menuItemCollection.Find(ctx, bson.M{"groupid": bson.M{"$in": groupIDs}, "deleted_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return trash, err
+	}
+	if err := cursor.All(ctx, &trash.Items); err != nil {
+		return trash, err
+	}
+
+	return trash, nil
+}
+
+// PurgeTrash permanently deletes records that have been in the trash for longer
+// than retention. Each menu is purged in its own transaction together with its
+// groups, items, tables, versions and scheduled publishes, and each group with
+// its items. Every instance runs the purge; the first to delete a menu or group
+// claims it, and the others find nothing left to purge.
+func PurgeTrash(ctx context.Context, retention time.Duration) error {
+	before := time.Now().Add(-retention)
+
+	menuIDs, err := trashedBefore(ctx, menuCollection, before)
+	if err != nil {
+		return err
+	}
+	for _, menuID := range menuIDs {
+		if err := purgeMenu(ctx, menuID, before); err != nil {
+			return err
+		}
+	}
+
+	groupIDs, err := trashedBefore(ctx, menuGroupCollection, before)
+	if err != nil {
+		return err
+	}
+	for _, groupID := range groupIDs {
+		if err := purgeGroup(ctx, groupID, before); err != nil {
+			return err
+		}
+	}
+
+	_, err = menuItemCollection.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": before}})
+	return err
+}
+
+func trashedBefore(ctx context.Context, collection *mongo.Collection, before time.Time) ([]primitive.ObjectID, error) {
+	values, err := collection.Distinct(ctx, "_id", bson.M{"deleted_at": bson.M{"$lt": before}})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// purgeMenu deletes a menu that is still in the trash since before, and everything
+// that belongs to it. A menu that was restored or purged in the meantime is left
+// alone.
+func purgeMenu(ctx context.Context, menuID primitive.ObjectID, before time.Time) error {
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := menuCollection.DeleteOne(sc, bson.M{"_id": menuID, "deleted_at": bson.M{"$lt": before}})
+		if err != nil || result.DeletedCount == 0 {
+			return err
+		}
+
+		groupIDs, err := findGroupIDs(sc, bson.M{"menuid": menuID.Hex()})
+		if err != nil {
+			return err
+		}
+		if _, err := menuItemCollection.DeleteMany(sc, bson.M{"groupid": bson.M{"$in": groupIDs}}); err != nil {
+			return err
+		}
+		if _, err := menuGroupCollection.DeleteMany(sc, bson.M{"menuid": menuID.Hex()}); err != nil {
+			return err
+		}
+		if _, err := tableCollection.DeleteMany(sc, bson.M{"menuid": menuID.Hex()}); err != nil {
+			return err
+		}
+		if _, err := menuVersionCollection.DeleteMany(sc, bson.M{"menuid": menuID.Hex()}); err != nil {
+			return err
+		}
+		_, err = scheduledPublishCollection.DeleteMany(sc, bson.M{"menuid": menuID.Hex()})
+		return err
+	})
+}
+
+// purgeGroup deletes a group that is still in the trash since before, with its
+// items.
+func purgeGroup(ctx context.Context, groupID primitive.ObjectID, before time.Time) error {
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := menuGroupCollection.DeleteOne(sc, bson.M{"_id": groupID, "deleted_at": bson.M{"$lt": before}})
+		if err != nil || result.DeletedCount == 0 {
+			return err
+		}
+
+		_, err = menuItemCollection.DeleteMany(sc, bson.M{"groupid": groupID.Hex()})
+		return err
+	})
+}
+
+// StartTrashPurger runs PurgeTrash every interval until the process exits.
+func StartTrashPurger(retention time.Duration, interval time.Duration) {
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			if err := PurgeTrash(ctx, retention); err != nil {
+				log.Println("trash purge failed:", err)
+			}
+			cancel()
+			time.Sleep(interval)
+		}
+	}()
+}
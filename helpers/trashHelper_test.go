@@ -0,0 +1,87 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPurgeTrashDeletesExpiredMenusWithEverythingUnderThem(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const retention = 30 * 24 * time.Hour
+	expired := time.Now().Add(-retention - time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	oldMenu, keptMenu, liveMenu := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	oldGroup, keptGroup, liveGroup, oldLooseGroup := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	oldItem, keptItem, liveItem, oldLooseItem, oldItemOfLiveGroup := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	oldTable, oldVersion := primitive.NewObjectID(), primitive.NewObjectID()
+
+	seeds := []struct {
+		collection *mongo.Collection
+		document   bson.M
+	}{
+		{menuCollection, bson.M{"_id": oldMenu, "deleted_at": expired}},
+		{menuCollection, bson.M{"_id": keptMenu, "deleted_at": recent}},
+		{menuCollection, bson.M{"_id": liveMenu, "deleted_at": nil}},
+		{menuGroupCollection, bson.M{"_id": oldGroup, "menuid": oldMenu.Hex(), "deleted_at": expired}},
+		{menuGroupCollection, bson.M{"_id": keptGroup, "menuid": keptMenu.Hex(), "deleted_at": recent}},
+		{menuGroupCollection, bson.M{"_id": liveGroup, "menuid": liveMenu.Hex(), "deleted_at": nil}},
+		{menuGroupCollection, bson.M{"_id": oldLooseGroup, "menuid": liveMenu.Hex(), "deleted_at": expired}},
+		{menuItemCollection, bson.M{"_id": oldItem, "groupid": oldGroup.Hex(), "deleted_at": expired}},
+		{menuItemCollection, bson.M{"_id": keptItem, "groupid": keptGroup.Hex(), "deleted_at": recent}},
+		{menuItemCollection, bson.M{"_id": liveItem, "groupid": liveGroup.Hex(), "deleted_at": nil}},
+		{menuItemCollection, bson.M{"_id": oldLooseItem, "groupid": oldLooseGroup.Hex(), "deleted_at": expired}},
+		{menuItemCollection, bson.M{"_id": oldItemOfLiveGroup, "groupid": liveGroup.Hex(), "deleted_at": expired}},
+		{tableCollection, bson.M{"_id": oldTable, "menuid": oldMenu.Hex(), "number": 1}},
+		{menuVersionCollection, bson.M{"_id": oldVersion, "menuid": oldMenu.Hex(), "version": 1}},
+	}
+	for _, seed := range seeds {
+		if _, err := seed.collection.InsertOne(ctx, seed.document); err != nil {
+			t.Fatal(err)
+		}
+		seed := seed
+		defer seed.collection.DeleteOne(context.Background(), bson.M{"_id": seed.document["_id"]})
+	}
+
+	// Every instance purges on its own schedule.
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- PurgeTrash(ctx, retention)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, seed := range seeds {
+		id := seed.document["_id"].(primitive.ObjectID)
+		count, err := seed.collection.CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := id == keptMenu || id == liveMenu || id == keptGroup || id == liveGroup || id == keptItem || id == liveItem
+		if kept && count != 1 {
+			t.Errorf("%s %s was purged", seed.collection.Name(), id.Hex())
+		}
+		if !kept && count != 0 {
+			t.Errorf("%s %s was not purged", seed.collection.Name(), id.Hex())
+		}
+	}
+}
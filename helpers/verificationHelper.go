@@ -0,0 +1,226 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	VerifyEmail = "email"
+	VerifyPhone = "phone"
+)
+
+// verificationTTL is how long a verification code stays valid.
+const verificationTTL = 30 * time.Minute
+
+// maxVerificationAttempts is how many wrong guesses a code survives before a new
+// one has to be requested.
+const maxVerificationAttempts = 5
+
+var verificationCollection *mongo.Collection = database.OpenCollection(database.Client, "verification")
+
+var (
+	ErrInvalidVerificationCode = errors.New("The verification code is invalid or has expired")
+	ErrAlreadyVerified         = errors.New("Already verified")
+)
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := verificationCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "channel", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("could not create verification indexes:", err)
+	}
+}
+
+// RequireEmailVerification reports whether users have to verify their email
+// before they may create a menu, based on REQUIRE_EMAIL_VERIFICATION.
+func RequireEmailVerification() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	return required
+}
+
+// VerificationResendCooldown returns how long a user has to wait before another
+// code is sent on the same channel, based on VERIFICATION_RESEND_SECONDS.
+func VerificationResendCooldown() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("VERIFICATION_RESEND_SECONDS"))
+	if err != nil || seconds < 0 {
+		seconds = 60
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// VerificationWait returns how long the user has to wait before another code may
+// be sent on the channel, or zero if one may be sent now.
+func VerificationWait(ctx context.Context, userID string, channel string) (time.Duration, error) {
+	var last models.Verification
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdat", Value: -1}})
+	err := verificationCollection.FindOne(ctx, bson.M{"userid": userID, "channel": channel}, opts).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(last.CreatedAt.Add(VerificationResendCooldown()))
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// hashVerificationCode returns the form a code is stored in. The user id is mixed
+// in so equal codes of different users are stored differently.
+func hashVerificationCode(userID string, code string) string {
+	sum := sha256.Sum256([]byte(userID + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func verificationTarget(user models.User, channel string) string {
+	target := user.Email
+	if channel == VerifyPhone {
+		target = user.Phone
+	}
+	if target == nil {
+		return ""
+	}
+	return *target
+}
+
+func isVerified(user models.User, channel string) bool {
+	if channel == VerifyPhone {
+		return user.Phone_verified
+	}
+	return user.Email_verified
+}
+
+// SendVerification sends a new six digit code to the email or phone of the user.
+// Earlier codes of the channel stop working.
+func SendVerification(ctx context.Context, user models.User, channel string) error {
+	if isVerified(user, channel) {
+		return ErrAlreadyVerified
+	}
+	target := verificationTarget(user, channel)
+
+	number, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", number.Int64())
+
+	if _, err := verificationCollection.DeleteMany(ctx, bson.M{"userid": user.User_id, "channel": channel, "usedat": nil}); err != nil {
+		return err
+	}
+	now := time.Now()
+	verification := models.Verification{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.User_id,
+		Channel:   channel,
+		Target:    target,
+		CodeHash:  hashVerificationCode(user.User_id, code),
+		ExpiresAt: now.Add(verificationTTL),
+		CreatedAt: now,
+	}
+	if _, err := verificationCollection.InsertOne(ctx, verification); err != nil {
+		return err
+	}
+
+	minutes := int(verificationTTL.Minutes())
+	if channel == VerifyPhone {
+		sender, err := OutgoingSMSSender()
+		if err != nil {
+			return err
+		}
+		return sender.Send(ctx, target, fmt.Sprintf("Your verification code is %s. It is valid for %d minutes.", code, minutes))
+	}
+
+	mailer, err := OutgoingMailer()
+	if err != nil {
+		return err
+	}
+	link := ""
+	if base := os.Getenv("VERIFY_EMAIL_URL"); base != "" {
+		link = "\n\nOr open " + base + "?code=" + code
+	}
+	body := fmt.Sprintf("Your verification code is %s.%s\n\nIt is valid for %d minutes.\n", code, link, minutes)
+	return mailer.Send(ctx, Mail{To: target, Subject: "Verify your email", Body: body})
+}
+
+// ConfirmVerification checks the code the user received on the channel and marks
+// the email or phone as verified. A code only counts for the address it was sent
+// to, and is used up after maxVerificationAttempts wrong guesses.
+func ConfirmVerification(ctx context.Context, userID string, channel string, code string) error {
+	var verification models.Verification
+	filter := bson.M{
+		"userid":    userID,
+		"channel":   channel,
+		"usedat":    nil,
+		"expiresat": bson.M{"$gt": time.Now()},
+		"attempts":  bson.M{"$lt": maxVerificationAttempts},
+	}
+	err := verificationCollection.FindOne(ctx, filter).Decode(&verification)
+	if err == mongo.ErrNoDocuments {
+		return ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return err
+	}
+
+	hash := hashVerificationCode(userID, code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(verification.CodeHash)) != 1 {
+		_, err := verificationCollection.UpdateOne(ctx, bson.M{"_id": verification.ID}, bson.M{"$inc": bson.M{"attempts": 1}})
+		if err != nil {
+			return err
+		}
+		return ErrInvalidVerificationCode
+	}
+
+	return WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+		result, err := verificationCollection.UpdateOne(sc,
+			bson.M{"_id": verification.ID, "usedat": nil},
+			bson.M{"$set": bson.M{"usedat": now}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return ErrInvalidVerificationCode
+		}
+
+		result, err = userCollection.UpdateOne(sc,
+			bson.M{"user_id": userID, channel: verification.Target},
+			bson.M{"$set": bson.M{channel + "_verified": true, "updated_at": now}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInvalidVerificationCode
+		}
+		return nil
+	})
+}
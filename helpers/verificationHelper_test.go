@@ -0,0 +1,202 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// recordingMailer stands in for the outgoing mailer and keeps what was sent.
+type recordingMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+var verificationCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// lastCode returns the code in the last mail sent to the address.
+func (m *recordingMailer) lastCode(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.mails) - 1; i >= 0; i-- {
+		if m.mails[i].To == to {
+			return verificationCodePattern.FindString(m.mails[i].Body)
+		}
+	}
+	t.Fatalf("no mail was sent to %s", to)
+	return ""
+}
+
+// useRecordingMailer makes OutgoingMailer return a recordingMailer for the rest
+// of the test binary.
+func useRecordingMailer() *recordingMailer {
+	recorder := &recordingMailer{}
+	mailerOnce.Do(func() {})
+	mailer, mailerErr = recorder, nil
+	return recorder
+}
+
+func setEnv(t *testing.T, name string, value string) {
+	t.Helper()
+	old, set := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func seedUnverifiedUser(t *testing.T) models.User {
+	t.Helper()
+	user := seedUser(t, models.User{})
+	t.Cleanup(func() {
+		verificationCollection.DeleteMany(context.Background(), bson.M{"userid": user.User_id})
+	})
+	return user
+}
+
+func TestVerificationResendCooldown(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    60 * time.Second,
+		"0":   0,
+		"90":  90 * time.Second,
+		"-1":  60 * time.Second,
+		"abc": 60 * time.Second,
+	}
+	for value, want := range tests {
+		setEnv(t, "VERIFICATION_RESEND_SECONDS", value)
+		if got := VerificationResendCooldown(); got != want {
+			t.Errorf("VERIFICATION_RESEND_SECONDS=%q: cooldown = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestConfirmVerificationAcceptsTheSentCode(t *testing.T) {
+	requireDatabase(t)
+	recorder := useRecordingMailer()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUnverifiedUser(t)
+	if err := SendVerification(ctx, user, VerifyEmail); err != nil {
+		t.Fatal(err)
+	}
+	code := recorder.lastCode(t, *user.Email)
+
+	if err := ConfirmVerification(ctx, user.User_id, VerifyEmail, code); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": user.User_id}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Email_verified {
+		t.Error("email is not marked as verified")
+	}
+
+	if err := ConfirmVerification(ctx, user.User_id, VerifyEmail, code); err != ErrInvalidVerificationCode {
+		t.Errorf("second use: err = %v, want %v", err, ErrInvalidVerificationCode)
+	}
+}
+
+func TestConfirmVerificationRejectsExpiredCodes(t *testing.T) {
+	requireDatabase(t)
+	recorder := useRecordingMailer()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUnverifiedUser(t)
+	if err := SendVerification(ctx, user, VerifyEmail); err != nil {
+		t.Fatal(err)
+	}
+	code := recorder.lastCode(t, *user.Email)
+
+	_, err := verificationCollection.UpdateMany(ctx, bson.M{"userid": user.User_id},
+		bson.M{"$set": bson.M{"expiresat": time.Now().Add(-time.Second)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ConfirmVerification(ctx, user.User_id, VerifyEmail, code); err != ErrInvalidVerificationCode {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidVerificationCode)
+	}
+}
+
+func TestConfirmVerificationLimitsGuesses(t *testing.T) {
+	requireDatabase(t)
+	recorder := useRecordingMailer()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUnverifiedUser(t)
+	if err := SendVerification(ctx, user, VerifyEmail); err != nil {
+		t.Fatal(err)
+	}
+	code := recorder.lastCode(t, *user.Email)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < maxVerificationAttempts; i++ {
+		if err := ConfirmVerification(ctx, user.User_id, VerifyEmail, wrong); err != ErrInvalidVerificationCode {
+			t.Fatalf("guess %d: err = %v, want %v", i+1, err, ErrInvalidVerificationCode)
+		}
+	}
+	if err := ConfirmVerification(ctx, user.User_id, VerifyEmail, code); err != ErrInvalidVerificationCode {
+		t.Fatalf("right code after too many guesses: err = %v, want %v", err, ErrInvalidVerificationCode)
+	}
+}
+
+func TestVerificationWaitEnforcesTheCooldown(t *testing.T) {
+	requireDatabase(t)
+	useRecordingMailer()
+	setEnv(t, "VERIFICATION_RESEND_SECONDS", "60")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user := seedUnverifiedUser(t)
+	if wait, err := VerificationWait(ctx, user.User_id, VerifyEmail); err != nil || wait != 0 {
+		t.Fatalf("before the first code: wait = %v, err = %v", wait, err)
+	}
+
+	if err := SendVerification(ctx, user, VerifyEmail); err != nil {
+		t.Fatal(err)
+	}
+	wait, err := VerificationWait(ctx, user.User_id, VerifyEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 50*time.Second || wait > 60*time.Second {
+		t.Errorf("right after sending: wait = %v, want about a minute", wait)
+	}
+	if wait, err := VerificationWait(ctx, user.User_id, VerifyPhone); err != nil || wait != 0 {
+		t.Errorf("other channel: wait = %v, err = %v", wait, err)
+	}
+
+	_, err = verificationCollection.UpdateMany(ctx, bson.M{"userid": user.User_id},
+		bson.M{"$set": bson.M{"createdat": time.Now().Add(-61 * time.Second)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := VerificationWait(ctx, user.User_id, VerifyEmail); err != nil || wait != 0 {
+		t.Errorf("after the cooldown: wait = %v, err = %v", wait, err)
+	}
+}
@@ -0,0 +1,367 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/sencerarslan/go-app/database"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var menuVersionCollection *mongo.Collection = database.OpenCollection(database.Client, "menu-version")
+
+var ErrNotPublished = errors.New("The menu has not been published yet")
+
+func init() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := menuVersionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "menuid", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println("could not create menu-version indexes:", err)
+	}
+}
+
+// PublishMenu stores the current draft of the menu as a new version and makes it
+// the one guests see.
+func PublishMenu(ctx context.Context, menuID primitive.ObjectID, userID string, note *string) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	err := WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		draft, err := LoadMenuTree(sc, menuID)
+		if err != nil {
+			return err
+		}
+		version, err = publishSnapshot(sc, draft, userID, note)
+		return err
+	})
+	return version, err
+}
+
+func publishSnapshot(ctx context.Context, snapshot models.Menu, userID string, note *string) (models.MenuVersion, error) {
+	var menu models.Menu
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := menuCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": snapshot.ID, "deleted_at": nil},
+		bson.M{"$inc": bson.M{"latestversion": 1}}, opts).Decode(&menu)
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+
+	now := time.Now()
+	snapshot.UserID = menu.UserID
+	snapshot.LatestVersion = menu.LatestVersion
+	snapshot.PublishedVersion = menu.LatestVersion
+	snapshot.PublishedAt = &now
+
+	version := models.MenuVersion{
+		ID:        primitive.NewObjectID(),
+		MenuID:    snapshot.ID.Hex(),
+		Version:   menu.LatestVersion,
+		UserID:    userID,
+		Note:      note,
+		Menu:      snapshot,
+		CreatedAt: now,
+	}
+	if _, err := menuVersionCollection.InsertOne(ctx, version); err != nil {
+		return version, err
+	}
+
+	_, err = menuCollection.UpdateOne(ctx,
+		bson.M{"_id": snapshot.ID, "publishedversion": bson.M{"$lt": version.Version}},
+		bson.M{"$set": bson.M{"publishedversion": version.Version, "publishedat": now}})
+	return version, err
+}
+
+// ListMenuVersions returns the versions of a menu, newest first, without their
+// contents.
+func ListMenuVersions(ctx context.Context, menuID string) ([]models.MenuVersion, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetProjection(bson.M{"menu": 0})
+	cursor, err := menuVersionCollection.Find(ctx, bson.M{"menuid": menuID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := make([]models.MenuVersion, 0)
+	err = cursor.All(ctx, &versions)
+	return versions, err
+}
+
+// FindMenuVersion loads one version of a menu with its contents.
+func FindMenuVersion(ctx context.Context, menuID string, version int) (models.MenuVersion, error) {
+	var found models.MenuVersion
+	err := menuVersionCollection.FindOne(ctx, bson.M{"menuid": menuID, "version": version}).Decode(&found)
+	return found, err
+}
+
+// LoadPublishedMenu returns the published snapshot of a menu. Sold-out flags and
+// stock counts are taken from the live items, since they change during service
+// without a new version being published.
+func LoadPublishedMenu(ctx context.Context, menuID primitive.ObjectID) (models.Menu, error) {
+	var menu models.Menu
+	err := menuCollection.FindOne(ctx, bson.M{"_id": menuID, "deleted_at": nil}).Decode(&menu)
+	if err != nil {
+		return menu, err
+	}
+	if menu.PublishedVersion == 0 {
+		return menu, ErrNotPublished
+	}
+
+	version, err := FindMenuVersion(ctx, menuID.Hex(), menu.PublishedVersion)
+	if err != nil {
+		return menu, err
+	}
+	snapshot := version.Menu
+
+	itemIDs := make([]primitive.ObjectID, 0)
+	for _, group := range snapshot.MenuGroup {
+		for _, item := range group.MenuItem {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+	opts := options.Find().SetProjection(bson.M{"soldout": 1, "stock": 1})
+	cursor, err := menuItemCollection.Find(ctx, bson.M{"_id": bson.M{"$in": itemIDs}}, opts)
+	if err != nil {
+		return snapshot, err
+	}
+	var live []models.MenuItem
+	if err := cursor.All(ctx, &live); err != nil {
+		return snapshot, err
+	}
+	liveByID := make(map[primitive.ObjectID]models.MenuItem, len(live))
+	for _, item := range live {
+		liveByID[item.ID] = item
+	}
+
+	for i := range snapshot.MenuGroup {
+		items := snapshot.MenuGroup[i].MenuItem
+		for j := range items {
+			if item, found := liveByID[items[j].ID]; found {
+				items[j].SoldOut = item.SoldOut
+				items[j].Stock = item.Stock
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// FindPublishedItem looks an item up in a published snapshot.
+func FindPublishedItem(menu models.Menu, itemID primitive.ObjectID) (models.MenuGroup, models.MenuItem, bool) {
+	for _, group := range menu.MenuGroup {
+		for _, item := range group.MenuItem {
+			if item.ID == itemID {
+				return group, item, true
+			}
+		}
+	}
+	return models.MenuGroup{}, models.MenuItem{}, false
+}
+
+// RollbackMenu publishes an earlier version again and resets the draft to it.
+// Groups and items added to the draft since then are moved to the trash.
+func RollbackMenu(ctx context.Context, menuID primitive.ObjectID, number int, userID string) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	err := WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		previous, err := FindMenuVersion(sc, menuID.Hex(), number)
+		if err != nil {
+			return err
+		}
+		if err := restoreDraft(sc, previous.Menu); err != nil {
+			return err
+		}
+
+		note := fmt.Sprintf("Rollback to version %d", number)
+		version, err = publishSnapshot(sc, previous.Menu, userID, &note)
+		return err
+	})
+	return version, err
+}
+
+// restoreDraft writes the snapshot back over the draft. Sold-out flags and stock
+// counts of existing items are left alone.
+func restoreDraft(ctx context.Context, snapshot models.Menu) error {
+	now := time.Now()
+	menuID := snapshot.ID.Hex()
+
+	_, err := menuCollection.UpdateOne(ctx, bson.M{"_id": snapshot.ID}, bson.M{"$set": bson.M{
+		"name":          snapshot.Name,
+		"logo":          snapshot.Logo,
+		"banner":        snapshot.Banner,
+		"currency":      snapshot.Currency,
+		"timezone":      snapshot.Timezone,
+		"schedule":      snapshot.Schedule,
+		"defaultlocale": snapshot.DefaultLocale,
+		"locales":       snapshot.Locales,
+		"translations":  snapshot.Translations,
+		"updatedat":     now,
+	}})
+	if err != nil {
+		return err
+	}
+
+	upsert := options.Update().SetUpsert(true)
+	groupIDs := make([]primitive.ObjectID, 0, len(snapshot.MenuGroup))
+	itemIDs := make([]primitive.ObjectID, 0)
+	for _, group := range snapshot.MenuGroup {
+		groupIDs = append(groupIDs, group.ID)
+		_, err := menuGroupCollection.UpdateOne(ctx, bson.M{"_id": group.ID}, bson.M{
+			"$set": bson.M{
+				"menuid":       menuID,
+				"name":         group.Name,
+				"position":     group.Position,
+				"schedule":     group.Schedule,
+				"translations": group.Translations,
+				"updatedat":    now,
+			},
+			"$unset":       bson.M{"deleted_at": ""},
+			"$setOnInsert": bson.M{"menuitem": bson.A{}, "createdat": group.CreatedAt},
+		}, upsert)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range group.MenuItem {
+			itemIDs = append(itemIDs, item.ID)
+			_, err := menuItemCollection.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{
+				"$set": bson.M{
+					"groupid":      group.ID.Hex(),
+					"name":         item.Name,
+					"price":        item.Price,
+					"optiongroup":  item.OptionGroup,
+					"description":  item.Description,
+					"imageurl":     item.ImageURL,
+					"position":     item.Position,
+					"schedule":     item.Schedule,
+					"translations": item.Translations,
+					"updatedat":    now,
+				},
+				"$unset":       bson.M{"deleted_at": ""},
+				"$setOnInsert": bson.M{"soldout": item.SoldOut, "soldoutbystock": item.SoldOutByStock, "stock": item.Stock, "createdat": item.CreatedAt},
+			}, upsert)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	allGroupIDs, err := findGroupIDs(ctx, bson.M{"menuid": menuID})
+	if err != nil {
+		return err
+	}
+	if _, err := menuItemCollection.UpdateMany(ctx,
+		bson.M{"groupid": bson.M{"$in": allGroupIDs}, "_id": bson.M{"$nin": itemIDs}, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+		return err
+	}
+	_, err = menuGroupCollection.UpdateMany(ctx,
+		bson.M{"menuid": menuID, "_id": bson.M{"$nin": groupIDs}, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": now}})
+	return err
+}
+
+type MenuChange struct {
+	Type   string   `json:"type"`
+	ID     string   `json:"id"`
+	Name   *string  `json:"name"`
+	Change string   `json:"change"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// changedFields compares two records by their JSON fields and returns the names of
+// the fields that differ, leaving out the ignored ones.
+func changedFields(from interface{}, to interface{}, ignore ...string) []string {
+	var a, b map[string]interface{}
+	encoded, _ := json.Marshal(from)
+	json.Unmarshal(encoded, &a)
+	encoded, _ = json.Marshal(to)
+	json.Unmarshal(encoded, &b)
+
+	for _, name := range ignore {
+		delete(a, name)
+		delete(b, name)
+	}
+
+	fields := make([]string, 0)
+	for name, value := range a {
+		if !reflect.DeepEqual(value, b[name]) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range b {
+		if _, found := a[name]; !found {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+var (
+	menuDiffIgnored  = []string{"ID", "user_id", "menu_groups", "latest_version", "published_version", "published_at", "created_at", "updated_at", "deleted_at"}
+	groupDiffIgnored = []string{"menu_items", "created_at", "updated_at", "deleted_at"}
+	// Stock is changed during service and is not part of a version.
+	itemDiffIgnored = []string{"sold_out", "stock", "created_at", "updated_at", "deleted_at"}
+)
+
+// DiffMenus lists what changed in the menu, its groups and its items between two
+// versions.
+func DiffMenus(from models.Menu, to models.Menu) []MenuChange {
+	changes := make([]MenuChange, 0)
+	if fields := changedFields(from, to, menuDiffIgnored...); len(fields) > 0 {
+		changes = append(changes, MenuChange{Type: "menu", ID: to.ID.Hex(), Name: to.Name, Change: "changed", Fields: fields})
+	}
+
+	oldGroups := make(map[primitive.ObjectID]models.MenuGroup)
+	oldItems := make(map[primitive.ObjectID]models.MenuItem)
+	for _, group := range from.MenuGroup {
+		oldGroups[group.ID] = group
+		for _, item := range group.MenuItem {
+			oldItems[item.ID] = item
+		}
+	}
+
+	for _, group := range to.MenuGroup {
+		if old, found := oldGroups[group.ID]; !found {
+			changes = append(changes, MenuChange{Type: "group", ID: group.ID.Hex(), Name: group.Name, Change: "added"})
+		} else if fields := changedFields(old, group, groupDiffIgnored...); len(fields) > 0 {
+			changes = append(changes, MenuChange{Type: "group", ID: group.ID.Hex(), Name: group.Name, Change: "changed", Fields: fields})
+		}
+		delete(oldGroups, group.ID)
+
+		for _, item := range group.MenuItem {
+			if old, found := oldItems[item.ID]; !found {
+				changes = append(changes, MenuChange{Type: "item", ID: item.ID.Hex(), Name: item.Name, Change: "added"})
+			} else if fields := changedFields(old, item, itemDiffIgnored...); len(fields) > 0 {
+				changes = append(changes, MenuChange{Type: "item", ID: item.ID.Hex(), Name: item.Name, Change: "changed", Fields: fields})
+			}
+			delete(oldItems, item.ID)
+		}
+	}
+
+	for _, group := range from.MenuGroup {
+		if _, found := oldGroups[group.ID]; found {
+			changes = append(changes, MenuChange{Type: "group", ID: group.ID.Hex(), Name: group.Name, Change: "removed"})
+		}
+		for _, item := range group.MenuItem {
+			if _, found := oldItems[item.ID]; found {
+				changes = append(changes, MenuChange{Type: "item", ID: item.ID.Hex(), Name: item.Name, Change: "removed"})
+			}
+		}
+	}
+	return changes
+}
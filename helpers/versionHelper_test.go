@@ -0,0 +1,127 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRollbackMenuRestoresTheDraft(t *testing.T) {
+	requireDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stale := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	name := func(s string) *string { return &s }
+	price, _ := models.ParseMoney("10")
+	menu := models.Menu{
+		ID:           primitive.NewObjectID(),
+		UserID:       name("owner"),
+		Name:         name("Menu"),
+		Logo:         name("logo.png"),
+		Banner:       name("banner.png"),
+		Translations: map[string]models.Translation{},
+		MenuGroup:    []models.MenuGroup{},
+		CreatedAt:    stale,
+		UpdatedAt:    stale,
+	}
+	group := models.MenuGroup{
+		ID:           primitive.NewObjectID(),
+		MenuID:       name(menu.ID.Hex()),
+		Name:         name("Soups"),
+		Translations: map[string]models.Translation{},
+		MenuItem:     []models.MenuItem{},
+		CreatedAt:    stale,
+		UpdatedAt:    stale,
+	}
+	item := models.MenuItem{
+		ID:           primitive.NewObjectID(),
+		GroupID:      name(group.ID.Hex()),
+		Name:         name("Lentil"),
+		Price:        price,
+		OptionGroup:  []models.OptionGroup{},
+		Description:  name("Lentil soup"),
+		ImageURL:     name("soup.png"),
+		Translations: map[string]models.Translation{},
+		CreatedAt:    stale,
+		UpdatedAt:    stale,
+	}
+	defer func() {
+		ctx := context.Background()
+		menuCollection.DeleteOne(ctx, bson.M{"_id": menu.ID})
+		menuGroupCollection.DeleteMany(ctx, bson.M{"menuid": menu.ID.Hex()})
+		menuItemCollection.DeleteMany(ctx, bson.M{"groupid": group.ID.Hex()})
+		menuVersionCollection.DeleteMany(ctx, bson.M{"menuid": menu.ID.Hex()})
+	}()
+	if _, err := menuCollection.InsertOne(ctx, menu); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := menuGroupCollection.InsertOne(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := menuItemCollection.InsertOne(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PublishMenu(ctx, menu.ID, "owner", nil); err != nil {
+		t.Fatal(err)
+	}
+	// Edit the draft after version 1, leaving the timestamps stale.
+	if _, err := menuItemCollection.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": bson.M{"name": "Tomato"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := menuCollection.UpdateOne(ctx, bson.M{"_id": menu.ID}, bson.M{"$set": bson.M{"name": "Renamed"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RollbackMenu(ctx, menu.ID, 1, "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	var restoredMenu models.Menu
+	if err := menuCollection.FindOne(ctx, bson.M{"_id": menu.ID}).Decode(&restoredMenu); err != nil {
+		t.Fatal(err)
+	}
+	var restoredGroup models.MenuGroup
+	if err := menuGroupCollection.FindOne(ctx, bson.M{"_id": group.ID}).Decode(&restoredGroup); err != nil {
+		t.Fatal(err)
+	}
+	var restoredItem models.MenuItem
+	if err := menuItemCollection.FindOne(ctx, bson.M{"_id": item.ID}).Decode(&restoredItem); err != nil {
+		t.Fatal(err)
+	}
+
+	if *restoredMenu.Name != "Menu" || *restoredItem.Name != "Lentil" {
+		t.Errorf("names = %q and %q, want the version 1 names", *restoredMenu.Name, *restoredItem.Name)
+	}
+	for kind, updatedAt := range map[string]time.Time{
+		"menu":  restoredMenu.UpdatedAt,
+		"group": restoredGroup.UpdatedAt,
+		"item":  restoredItem.UpdatedAt,
+	} {
+		if !updatedAt.After(stale) {
+			t.Errorf("%s updatedat = %v, want it moved on from %v", kind, updatedAt, stale)
+		}
+	}
+
+	strays := []struct {
+		kind       string
+		collection *mongo.Collection
+		id         primitive.ObjectID
+	}{
+		{"menu", menuCollection, menu.ID},
+		{"group", menuGroupCollection, group.ID},
+		{"item", menuItemCollection, item.ID},
+	}
+	for _, stray := range strays {
+		n, err := stray.collection.CountDocuments(ctx, bson.M{"_id": stray.id, "updated_at": bson.M{"$exists": true}})
+		if err != nil || n != 0 {
+			t.Errorf("%s has a stray updated_at field (count %d, err %v)", stray.kind, n, err)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package helper
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// This file holds a small encoder for the lossless WebP (VP8L) format, since the
+// Go image libraries only decode WebP. It uses the subtract-green and predictor
+// transforms, one set of prefix codes for the whole image and backward references
+// only for runs of the same pixel. That is enough for web thumbnails; the format
+// is described at
+// https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	vp8lMaxSize        = 1 << 14
+	vp8lPredictorBits  = 9
+	vp8lPredictorMode  = 12
+	vp8lMaxCodeLength  = 15
+	vp8lMaxCodeLenBits = 7
+	vp8lMinRun         = 3
+	vp8lMaxRun         = 4096
+	// vp8lLeftDistance is the distance code of the pixel to the left.
+	vp8lLeftDistance = 2
+)
+
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+var ErrImageTooLarge = errors.New("Image is too large")
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// write appends the n lowest bits of value, least significant bit first.
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// huffmanLengths returns code lengths of at most limit bits for the symbol counts.
+// Counts are halved until the tree fits, which keeps the code close to optimal.
+func huffmanLengths(counts []int, limit int) []uint8 {
+	counts = append([]int(nil), counts...)
+	for {
+		lengths, depth := buildHuffman(counts)
+		if depth <= limit {
+			return lengths
+		}
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+func buildHuffman(counts []int) ([]uint8, int) {
+	lengths := make([]uint8, len(counts))
+
+	leaves := make([]int, 0, len(counts))
+	for symbol, count := range counts {
+		if count > 0 {
+			leaves = append(leaves, symbol)
+		}
+	}
+	if len(leaves) < 2 {
+		for _, symbol := range leaves {
+			lengths[symbol] = 1
+		}
+		return lengths, 1
+	}
+	sort.SliceStable(leaves, func(i, j int) bool { return counts[leaves[i]] < counts[leaves[j]] })
+
+	// Leaves are nodes 0..n-1, internal nodes follow in the order they are made.
+	// Both queues stay sorted by weight, so the two lightest nodes are always at
+	// their fronts.
+	n := len(leaves)
+	weight := make([]int, 2*n-1)
+	parent := make([]int, 2*n-1)
+	for i, symbol := range leaves {
+		weight[i] = counts[symbol]
+	}
+
+	nextLeaf, nextInner, made := 0, n, n
+	lightest := func() int {
+		if nextLeaf < n && (nextInner >= made || weight[nextLeaf] <= weight[nextInner]) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInner++
+		return nextInner - 1
+	}
+	for made < 2*n-1 {
+		a, b := lightest(), lightest()
+		weight[made] = weight[a] + weight[b]
+		parent[a], parent[b] = made, made
+		made++
+	}
+
+	depth := make([]int, 2*n-1)
+	maxDepth := 0
+	for node := 2*n - 3; node >= 0; node-- {
+		depth[node] = depth[parent[node]] + 1
+		if node < n {
+			lengths[leaves[node]] = uint8(depth[node])
+			if depth[node] > maxDepth {
+				maxDepth = depth[node]
+			}
+		}
+	}
+	return lengths, maxDepth
+}
+
+// canonicalCodes assigns canonical prefix codes to the lengths. The codes are bit
+// reversed because VP8L reads them least significant bit first.
+func canonicalCodes(lengths []uint8) []uint32 {
+	var lengthCount [vp8lMaxCodeLength + 1]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			lengthCount[length]++
+		}
+	}
+
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for bits := 1; bits <= vp8lMaxCodeLength; bits++ {
+		code = (code + lengthCount[bits-1]) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		value := next[length]
+		next[length]++
+		reversed := uint32(0)
+		for i := uint8(0); i < length; i++ {
+			reversed = reversed<<1 | value&1
+			value >>= 1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
+
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (p prefixCode) write(w *bitWriter, symbol int) {
+	w.write(p.codes[symbol], uint(p.lengths[symbol]))
+}
+
+// writePrefixCode writes the code for the symbol counts and returns it. Alphabets
+// with at most one used symbol take the short "simple" form and cost no bits per
+// symbol.
+func writePrefixCode(w *bitWriter, counts []int) prefixCode {
+	used := make([]int, 0, 2)
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 1 {
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+		w.write(1, 1)
+		w.write(0, 1)
+		if symbol < 2 {
+			w.write(0, 1)
+			w.write(uint32(symbol), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(symbol), 8)
+		}
+		return prefixCode{lengths: make([]uint8, len(counts)), codes: make([]uint32, len(counts))}
+	}
+
+	lengths := huffmanLengths(counts, vp8lMaxCodeLength)
+
+	lengthCounts := make([]int, len(vp8lCodeLengthOrder))
+	distinct := 0
+	for _, length := range lengths {
+		if lengthCounts[length] == 0 {
+			distinct++
+		}
+		lengthCounts[length]++
+	}
+	if distinct == 1 {
+		// The code length code needs two symbols to form a complete tree.
+		lengthCounts[(int(lengths[0])+1)%len(lengthCounts)] = 1
+	}
+	lengthCode := huffmanLengths(lengthCounts, vp8lMaxCodeLenBits)
+	lengthCodes := canonicalCodes(lengthCode)
+
+	written := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if lengthCode[symbol] > 0 && i+1 > written {
+			written = i + 1
+		}
+	}
+
+	w.write(0, 1)
+	w.write(uint32(written-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:written] {
+		w.write(uint32(lengthCode[symbol]), 3)
+	}
+	w.write(0, 1)
+	for _, length := range lengths {
+		w.write(lengthCodes[length], uint(lengthCode[length]))
+	}
+
+	return prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+// prefixEncode splits a length or distance into its prefix symbol and extra bits.
+func prefixEncode(value int) (symbol int, extraBits uint, extra uint32) {
+	d := value - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	high := uint(0)
+	for d>>(high+1) != 0 {
+		high++
+	}
+	second := d >> (high - 1) & 1
+	extraBits = high - 1
+	return int(2*high) + second, extraBits, uint32(d) & (1<<extraBits - 1)
+}
+
+func clampByte(v int32) uint32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint32(v)
+}
+
+// clampAddSubtractFull is predictor mode 12: L + T - TL for each channel.
+func clampAddSubtractFull(left uint32, top uint32, topLeft uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		v := int32(left>>shift&0xff) + int32(top>>shift&0xff) - int32(topLeft>>shift&0xff)
+		out |= clampByte(v) << shift
+	}
+	return out
+}
+
+func subPixels(a uint32, b uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		out |= ((a>>shift - b>>shift) & 0xff) << shift
+	}
+	return out
+}
+
+// EncodeWebP writes img as a lossless WebP image.
+func EncodeWebP(out io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return ErrImageTooLarge
+	}
+
+	pixels := make([]uint32, width*height)
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+			// Subtract green: red and blue are stored relative to green.
+			r, b := uint32(c.R-c.G), uint32(c.B-c.G)
+			pixels[y*width+x] = uint32(c.A)<<24 | r<<16 | uint32(c.G)<<8 | b
+		}
+	}
+
+	residuals := make([]uint32, len(pixels))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var predicted uint32
+			switch {
+			case x == 0 && y == 0:
+				predicted = 0xff000000
+			case y == 0:
+				predicted = pixels[i-1]
+			case x == 0:
+				predicted = pixels[i-width]
+			default:
+				predicted = clampAddSubtractFull(pixels[i-1], pixels[i-width], pixels[i-width-1])
+			}
+			residuals[i] = subPixels(pixels[i], predicted)
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if hasAlpha {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 3)
+
+	// Transforms are undone in reverse order, so the predictor is listed last.
+	w.write(1, 1)
+	w.write(2, 2)
+	w.write(1, 1)
+	w.write(0, 2)
+	w.write(vp8lPredictorBits-2, 3)
+	// The predictor image uses one mode everywhere: every code has a single
+	// symbol, so its pixels take no bits.
+	w.write(0, 1)
+	writePrefixCode(w, []int{vp8lPredictorMode: 1})
+	for i := 0; i < 4; i++ {
+		writePrefixCode(w, nil)
+	}
+	w.write(0, 1)
+
+	// A run is the count of pixels repeating the one before them; zero marks a
+	// literal pixel.
+	runs := make([]int, len(residuals))
+	green, red, blue, alpha := make([]int, 280), make([]int, 256), make([]int, 256), make([]int, 256)
+	distance := make([]int, 40)
+	for i := 0; i < len(residuals); {
+		run := 0
+		for i > 0 && i+run < len(residuals) && run < vp8lMaxRun && residuals[i+run] == residuals[i-1] {
+			run++
+		}
+		if run >= vp8lMinRun {
+			runs[i] = run
+			symbol, _, _ := prefixEncode(run)
+			green[256+symbol]++
+			distance[vp8lLeftDistance-1]++
+			i += run
+			continue
+		}
+
+		p := residuals[i]
+		alpha[p>>24]++
+		red[p>>16&0xff]++
+		green[p>>8&0xff]++
+		blue[p&0xff]++
+		i++
+	}
+
+	w.write(0, 1)
+	w.write(0, 1)
+	greenCode := writePrefixCode(w, green)
+	redCode := writePrefixCode(w, red)
+	blueCode := writePrefixCode(w, blue)
+	alphaCode := writePrefixCode(w, alpha)
+	distanceCode := writePrefixCode(w, distance)
+
+	for i := 0; i < len(residuals); {
+		if run := runs[i]; run > 0 {
+			symbol, extraBits, extra := prefixEncode(run)
+			greenCode.write(w, 256+symbol)
+			w.write(extra, extraBits)
+			distanceSymbol, _, _ := prefixEncode(vp8lLeftDistance)
+			distanceCode.write(w, distanceSymbol)
+			i += run
+			continue
+		}
+
+		p := residuals[i]
+		greenCode.write(w, int(p>>8&0xff))
+		redCode.write(w, int(p>>16&0xff))
+		blueCode.write(w, int(p&0xff))
+		alphaCode.write(w, int(p>>24))
+		i++
+	}
+
+	data := w.bytes()
+	padded := len(data) + len(data)%2
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	_, err := out.Write(data)
+	return err
+}
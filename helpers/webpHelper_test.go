@@ -0,0 +1,85 @@
+package helper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func webpTestImages() map[string]image.Image {
+	gradient := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 5), B: uint8(x ^ y), A: 0xff})
+		}
+	}
+
+	translucent := image.NewNRGBA(image.Rect(0, 0, 33, 17))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 33; x++ {
+			translucent.SetNRGBA(x, y, color.NRGBA{R: 200, G: uint8(x * 7), B: 30, A: uint8(y * 15)})
+		}
+	}
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	random := rand.New(rand.NewSource(1))
+	random.Read(noise.Pix)
+
+	flat := image.NewNRGBA(image.Rect(0, 0, 10, 3))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x80
+	}
+
+	pixel := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	pixel.SetNRGBA(0, 0, color.NRGBA{R: 1, G: 2, B: 3, A: 0xff})
+
+	return map[string]image.Image{
+		"gradient":    gradient,
+		"translucent": translucent,
+		"noise":       noise,
+		"flat":        flat,
+		"pixel":       pixel,
+		"sub image":   gradient.SubImage(image.Rect(10, 5, 30, 25)),
+	}
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	for name, img := range webpTestImages() {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bounds := img.Bounds()
+			if decoded.Bounds().Size() != bounds.Size() {
+				t.Fatalf("size = %v, want %v", decoded.Bounds().Size(), bounds.Size())
+			}
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					want := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+					got := color.NRGBAModel.Convert(decoded.At(decoded.Bounds().Min.X+x, decoded.Bounds().Min.Y+y))
+					if got != want {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsEmptyImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, image.NewNRGBA(image.Rect(0, 0, 0, 0))); err != ErrImageTooLarge {
+		t.Fatalf("err = %v, want %v", err, ErrImageTooLarge)
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	helper "github.com/sencerarslan/go-app/helpers"
 	routes "github.com/sencerarslan/go-app/routes"
 )
 
@@ -22,18 +26,45 @@ func main() {
 		port = "8000"
 	}
 
+	retentionDays, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || retentionDays < 1 {
+		retentionDays = 30
+	}
+	helper.StartTrashPurger(time.Duration(retentionDays)*24*time.Hour, time.Hour)
+
+	escalationMinutes, err := strconv.Atoi(os.Getenv("SERVICE_ESCALATION_MINUTES"))
+	if err != nil || escalationMinutes < 1 {
+		escalationMinutes = 5
+	}
+	helper.StartServiceEscalator(time.Duration(escalationMinutes)*time.Minute, 30*time.Second)
+	helper.StartPublishScheduler(30 * time.Second)
+
 	router := gin.Default()
+	// Login throttling and rate limits key on ClientIP, so forwarded headers are
+	// only believed when they come from a configured proxy.
+	if err := router.SetTrustedProxies(helper.TrustedProxies()); err != nil {
+		log.Fatal(err)
+	}
 
 	// CORS middleware
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"*"}
 	config.AllowMethods = []string{"GET", "POST"}
-	config.AllowHeaders = []string{"Content-Type", "Authorization", "Token"}
+	config.AllowHeaders = []string{"Content-Type", "Authorization", "Token", "X-API-Key"}
+	config.ExposeHeaders = []string{"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
 	router.Use(cors.New(config))
 
 	routes.AuthRoutes(router)
 	routes.UserRoutes(router)
 	routes.AuthMenuRoutes(router)
+	routes.TableRoutes(router)
+	routes.OrderRoutes(router)
+	routes.ServiceRoutes(router)
+	routes.UploadRoutes(router)
+
+	if dir, local := helper.LocalUploadDir(); local {
+		router.Static("/uploads", dir)
+	}
 
 	router.Run(":" + port)
 }
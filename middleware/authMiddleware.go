@@ -24,11 +24,61 @@ func Authenticate() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+
+		revoked, revokedErr := helper.IsTokenRevoked(claims)
+		if revokedErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": revokedErr.Error()})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("email", claims.Email)
 		c.Set("first_name", claims.First_name)
 		c.Set("last_name", claims.Last_name)
 		c.Set("uid", claims.Uid)
 		c.Set("user_type", claims.User_type)
+		c.Set("jti", claims.Id)
+		c.Set("family", claims.Family)
+		c.Set("expires_at", claims.ExpiresAt)
+		c.Next()
+	}
+}
+
+// AuthenticateStream authenticates the event stream with the stream token in the
+// token query parameter, for clients that cannot set request headers, such as the
+// browser EventSource API. Access tokens are not accepted there.
+func AuthenticateStream() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := helper.ValidateStreamToken(c.Query("token"))
+		if err != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err})
+			c.Abort()
+			return
+		}
+
+		revoked, revokedErr := helper.IsTokenRevoked(claims)
+		if revokedErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": revokedErr.Error()})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("uid", claims.Uid)
+		c.Set("user_type", claims.User_type)
+		c.Set("jti", claims.Id)
+		c.Set("family", claims.Family)
+		c.Set("expires_at", claims.ExpiresAt)
+		c.Set("stream_claims", claims)
 		c.Next()
 	}
 }
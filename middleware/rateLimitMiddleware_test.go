@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// limitedRouter answers /limited with a one request per minute ByIP policy and
+// trusts the proxies in TRUSTED_PROXIES, as main does.
+func limitedRouter(t *testing.T, name string) *gin.Engine {
+	t.Helper()
+	router := gin.New()
+	if err := router.SetTrustedProxies(helper.TrustedProxies()); err != nil {
+		t.Fatal(err)
+	}
+	policy := helper.RateLimitPolicy{Name: name, Limit: 1, Period: time.Minute}
+	router.GET("/limited", RateLimit(policy, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func requestFrom(router *gin.Engine, remoteAddr string, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestByIPIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	os.Unsetenv("TRUSTED_PROXIES")
+	router := limitedRouter(t, "untrusted")
+
+	if code := requestFrom(router, "198.51.100.7:4000", "203.0.113.1"); code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := requestFrom(router, "198.51.100.7:4001", "203.0.113.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestByIPUsesForwardedForFromTrustedProxies(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "198.51.100.0/24")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	router := limitedRouter(t, "trusted")
+
+	if code := requestFrom(router, "198.51.100.7:4000", "203.0.113.1"); code != http.StatusOK {
+		t.Fatalf("first client: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := requestFrom(router, "198.51.100.7:4001", "203.0.113.2"); code != http.StatusOK {
+		t.Fatalf("second client behind the proxy: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := requestFrom(router, "198.51.100.7:4002", "203.0.113.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("first client again: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitSendsHeadersAndRetryAfter(t *testing.T) {
+	router := gin.New()
+	policy := helper.RateLimitPolicy{Name: "headers", Limit: 2, Period: time.Minute}
+	router.GET("/limited", RateLimit(policy, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = "198.51.100.8:4000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send()
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	for header, want := range map[string]string{"RateLimit-Policy": "2;w=60", "RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "30"} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if w.Header().Get("Retry-After") != "" {
+		t.Error("allowed request carries Retry-After")
+	}
+
+	send()
+	w = send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 30 {
+		t.Errorf("Retry-After = %q, want between 1 and 30 seconds", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestByAPIKeyCountsOnlyConfiguredKeys(t *testing.T) {
+	os.Setenv("API_KEYS", "partner-key")
+	defer os.Unsetenv("API_KEYS")
+	router := gin.New()
+	policy := helper.RateLimitPolicy{Name: "apikey", Limit: 1, Period: time.Minute}
+	router.GET("/limited", RateLimit(policy, ByAPIKey, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = "198.51.100.9:4000"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(""); code != http.StatusOK {
+		t.Fatalf("without a key: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := send("made-up-key"); code != http.StatusTooManyRequests {
+		t.Fatalf("unknown key: status = %d, want %d from the address bucket", code, http.StatusTooManyRequests)
+	}
+	if code := send("partner-key"); code != http.StatusOK {
+		t.Fatalf("known key: status = %d, want %d from its own bucket", code, http.StatusOK)
+	}
+	if code := send("partner-key"); code != http.StatusTooManyRequests {
+		t.Fatalf("known key again: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoginAttempt struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Key           string             `json:"key"`
+	Failures      int                `json:"failures"`
+	LockedUntil   *time.Time         `json:"locked_until"`
+	LastFailureAt time.Time          `json:"last_failure_at"`
+	ExpiresAt     time.Time          `json:"expires_at"`
+}
+
+type AuditLog struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Event     string             `json:"event"`
+	Email     string             `json:"email"`
+	IP        string             `json:"ip"`
+	ActorID   string             `json:"actor_id"`
+	Details   string             `json:"details"`
+	CreatedAt time.Time          `json:"created_at"`
+}
@@ -7,30 +7,83 @@ import (
 )
 
 type Menu struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	UserID    *string            `json:"user_id"`
-	Name      *string            `json:"name" validate:"required"`
-	Logo      *string            `json:"logo" validate:"required"`
-	Banner    *string            `json:"banner" validate:"required"`
-	MenuGroup []MenuGroup        `json:"menu_groups"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
+	ID               primitive.ObjectID     `bson:"_id"`
+	UserID           *string                `json:"user_id"`
+	Name             *string                `json:"name" validate:"required"`
+	Logo             *string                `json:"logo" validate:"required"`
+	Banner           *string                `json:"banner" validate:"required"`
+	Currency         string                 `json:"currency" validate:"omitempty,iso4217"`
+	Timezone         string                 `json:"timezone" validate:"omitempty,timezone"`
+	Schedule         *Schedule              `json:"schedule" validate:"omitempty"`
+	DefaultLocale    string                 `json:"default_locale" validate:"omitempty,bcp47_language_tag"`
+	Locales          []string               `json:"locales" validate:"omitempty,dive,bcp47_language_tag"`
+	Translations     map[string]Translation `json:"translations"`
+	MenuGroup        []MenuGroup            `json:"menu_groups"`
+	LatestVersion    int                    `json:"latest_version"`
+	PublishedVersion int                    `json:"published_version"`
+	PublishedAt      *time.Time             `json:"published_at"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	DeletedAt        *time.Time             `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
 }
 type MenuGroup struct {
+	ID           primitive.ObjectID     `bson:"_id"`
+	MenuID       *string                `json:"menu_id" validate:"required"`
+	Name         *string                `json:"name" validate:"required"`
+	Position     int                    `json:"position"`
+	Schedule     *Schedule              `json:"schedule" validate:"omitempty"`
+	Translations map[string]Translation `json:"translations"`
+	MenuItem     []MenuItem             `json:"menu_items"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	DeletedAt    *time.Time             `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+type MenuItem struct {
+	ID             primitive.ObjectID     `bson:"_id"`
+	GroupID        *string                `json:"group_id"`
+	Name           *string                `json:"name" validate:"required"`
+	Price          Money                  `json:"price" validate:"required"`
+	OptionGroup    []OptionGroup          `json:"option_groups" validate:"dive"`
+	Description    *string                `json:"description" validate:"required"`
+	ImageURL       *string                `json:"image_url" validate:"required"`
+	Position       int                    `json:"position"`
+	Schedule       *Schedule              `json:"schedule" validate:"omitempty"`
+	SoldOut        bool                   `json:"sold_out"`
+	SoldOutByStock bool                   `json:"-"`
+	Stock          *int64                 `json:"stock" validate:"omitempty,gte=0"`
+	Translations   map[string]Translation `json:"translations"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	DeletedAt      *time.Time             `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+type OptionGroup struct {
 	ID        primitive.ObjectID `bson:"_id"`
-	MenuID    *string            `json:"menu_id" validate:"required"`
 	Name      *string            `json:"name" validate:"required"`
-	MenuItem  []MenuItem         `json:"menu_items"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
+	MinSelect int                `json:"min_select" validate:"gte=0"`
+	MaxSelect int                `json:"max_select" validate:"gte=1,gtefield=MinSelect"`
+	Options   []Option           `json:"options" validate:"required,min=1,dive"`
 }
-type MenuItem struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	GroupID     *string            `json:"group_id"`
-	Name        *string            `json:"name" validate:"required"`
-	Price       float64            `json:"price" validate:"required"`
-	Description *string            `json:"description" validate:"required"`
-	ImageURL    *string            `json:"image_url" validate:"required"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at"`
+type Option struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required"`
+	PriceDelta Money              `json:"price_delta"`
+}
+type Translation struct {
+	Name        *string `bson:"name,omitempty" json:"name,omitempty"`
+	Description *string `bson:"description,omitempty" json:"description,omitempty"`
+}
+type Schedule struct {
+	Windows   []TimeWindow   `json:"windows" validate:"dive"`
+	Overrides []DateOverride `json:"overrides" validate:"dive"`
+}
+type TimeWindow struct {
+	Days  []time.Weekday `json:"days" validate:"required,min=1,dive,gte=0,lte=6"`
+	Start string         `json:"start" validate:"required,datetime=15:04"`
+	End   string         `json:"end" validate:"required,datetime=15:04"`
+}
+type DateOverride struct {
+	From      string       `json:"from" validate:"required,datetime=2006-01-02"`
+	To        string       `json:"to" validate:"required,datetime=2006-01-02"`
+	Available bool         `json:"available"`
+	Windows   []TimeWindow `json:"windows" validate:"dive"`
 }
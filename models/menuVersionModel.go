@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MenuVersion struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	MenuID    string             `json:"menu_id"`
+	Version   int                `json:"version"`
+	UserID    string             `json:"user_id"`
+	Note      *string            `json:"note"`
+	Menu      Menu               `json:"menu"`
+	CreatedAt time.Time          `json:"created_at"`
+}
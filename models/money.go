@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Money is an exact decimal amount, stored as Decimal128. In JSON it is written as
+// a number and accepted as a number or a string, without passing through float64.
+type Money primitive.Decimal128
+
+func ParseMoney(s string) (Money, error) {
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		return Money{}, err
+	}
+	if d.IsNaN() || d.IsInf() != 0 {
+		return Money{}, errors.New("amount must be a finite number")
+	}
+	return Money(d), nil
+}
+
+// MoneyFromMinorUnits builds an amount from an integer count of minor units.
+func MoneyFromMinorUnits(units int64, digits int) Money {
+	return moneyFromBigInt(big.NewInt(units), -digits)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (m Money) String() string {
+	if m == (Money{}) {
+		return "0"
+	}
+	return primitive.Decimal128(m).String()
+}
+
+func (m Money) bigInt() (*big.Int, int) {
+	value, exp, err := primitive.Decimal128(m).BigInt()
+	if err != nil {
+		return big.NewInt(0), 0
+	}
+	return value, exp
+}
+
+// Scale returns the number of significant digits after the decimal point.
+func (m Money) Scale() int {
+	value, exp := m.bigInt()
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for exp < 0 && value.Sign() != 0 {
+		if _, r := new(big.Int).QuoRem(value, ten, remainder); r.Sign() != 0 {
+			break
+		}
+		value.Quo(value, ten)
+		exp++
+	}
+	if exp >= 0 || value.Sign() == 0 {
+		return 0
+	}
+	return -exp
+}
+
+func (m Money) Sign() int {
+	value, _ := m.bigInt()
+	return value.Sign()
+}
+
+// MinorUnits converts the amount to an integer count of minor units. It fails if
+// the amount has more decimals than digits.
+func (m Money) MinorUnits(digits int) (int64, error) {
+	if m.Scale() > digits {
+		return 0, errors.New("amount has too many decimals")
+	}
+	value, exp := m.bigInt()
+	if shift := digits + exp; shift >= 0 {
+		value.Mul(value, pow10(shift))
+	} else {
+		value.Quo(value, pow10(-shift))
+	}
+	if !value.IsInt64() {
+		return 0, errors.New("amount is out of range")
+	}
+	return value.Int64(), nil
+}
+
+func moneyFromBigInt(value *big.Int, exp int) Money {
+	d, ok := primitive.ParseDecimal128FromBigInt(value, exp)
+	if !ok {
+		return Money{}
+	}
+	return Money(d)
+}
+
+func (m Money) Add(other Money) Money {
+	a, aExp := m.bigInt()
+	b, bExp := other.bigInt()
+	if m == (Money{}) {
+		aExp = bExp
+	}
+	if other == (Money{}) {
+		bExp = aExp
+	}
+
+	if aExp > bExp {
+		a.Mul(a, pow10(aExp-bExp))
+		aExp = bExp
+	} else if bExp > aExp {
+		b.Mul(b, pow10(bExp-aExp))
+	}
+	return moneyFromBigInt(a.Add(a, b), aExp)
+}
+
+func (m Money) Mul(n int64) Money {
+	value, exp := m.bigInt()
+	return moneyFromBigInt(value.Mul(value, big.NewInt(n)), exp)
+}
+
+// Round rounds the amount half away from zero to the given number of decimals.
+func (m Money) Round(digits int) Money {
+	if m.Scale() <= digits {
+		return m
+	}
+
+	value, exp := m.bigInt()
+	divisor := pow10(-exp - digits)
+	quotient, remainder := new(big.Int).QuoRem(value, divisor, new(big.Int))
+	if new(big.Int).Mul(remainder.Abs(remainder), big.NewInt(2)).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+	return moneyFromBigInt(quotient, -digits)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(b, &text); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(b, &number); err != nil {
+			return errors.New("amount must be a number or a string")
+		}
+		text = number.String()
+	}
+
+	money, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.Decimal128(m))
+}
+
+// UnmarshalBSONValue also reads the float64 prices written before amounts were
+// stored as Decimal128.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		*m = Money(raw.Decimal128())
+	case bsontype.Double:
+		money, err := ParseMoney(strconv.FormatFloat(raw.Double(), 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*m = money
+	case bsontype.Int32:
+		*m = MoneyFromMinorUnits(int64(raw.Int32()), 0)
+	case bsontype.Int64:
+		*m = MoneyFromMinorUnits(raw.Int64(), 0)
+	case bsontype.Null:
+		*m = Money{}
+	default:
+		return errors.New("cannot decode " + t.String() + " into Money")
+	}
+	return nil
+}
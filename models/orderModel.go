@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Order struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	MenuID    string             `json:"menu_id"`
+	TableID   *string            `json:"table_id"`
+	TableName *string            `json:"table_name"`
+	Status    string             `json:"status"`
+	Items     []OrderItem        `json:"items"`
+	Currency  string             `json:"currency"`
+	Total     Money              `json:"total"`
+	Note      *string            `json:"note"`
+	History   []OrderStatus      `json:"history"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+type OrderItem struct {
+	ItemID    string        `json:"item_id"`
+	Name      *string       `json:"name"`
+	Quantity  int           `json:"quantity"`
+	UnitPrice Money         `json:"unit_price"`
+	Options   []OrderOption `json:"options"`
+	Total     Money         `json:"total"`
+	Note      *string       `json:"note"`
+}
+type OrderOption struct {
+	GroupID    primitive.ObjectID `json:"group_id"`
+	OptionID   primitive.ObjectID `json:"option_id"`
+	Name       *string            `json:"name"`
+	PriceDelta Money              `json:"price_delta"`
+}
+type OrderStatus struct {
+	Status string    `json:"status"`
+	UserID string    `json:"user_id,omitempty"`
+	At     time.Time `json:"at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasswordReset struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	UserID    string             `json:"user_id"`
+	TokenHash string             `json:"-"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokedToken is either a single revoked token (Jti set) or a cut-off that revokes
+// every token of UserID issued before IssuedBefore. Records are removed by a TTL index
+// once ExpiresAt has passed, since the tokens they cover are expired by then anyway.
+type RevokedToken struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Jti          string             `json:"jti"`
+	UserID       string             `json:"user_id"`
+	IssuedBefore time.Time          `json:"issued_before"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ScheduledPublish struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	MenuID      string             `json:"menu_id"`
+	UserID      string             `json:"user_id"`
+	Note        *string            `json:"note"`
+	PublishAt   time.Time          `json:"publish_at"`
+	LocalTime   string             `json:"local_time"`
+	Timezone    string             `json:"timezone"`
+	Status      string             `json:"status"`
+	Menu        Menu               `json:"menu"`
+	Version     int                `json:"version"`
+	Error       *string            `json:"error"`
+	ClaimedAt   *time.Time         `json:"-"`
+	PublishedAt *time.Time         `json:"published_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ServiceRequest struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	MenuID         string             `json:"menu_id"`
+	TableID        string             `json:"table_id"`
+	TableName      *string            `json:"table_name"`
+	Type           string             `json:"type"`
+	Status         string             `json:"status"`
+	Active         bool               `json:"-"`
+	Note           *string            `json:"note"`
+	DeviceID       string             `json:"-"`
+	Escalated      bool               `json:"escalated"`
+	EscalatedAt    *time.Time         `json:"escalated_at"`
+	AcknowledgedAt *time.Time         `json:"acknowledged_at"`
+	AcknowledgedBy *string            `json:"acknowledged_by"`
+	ResolvedAt     *time.Time         `json:"resolved_at"`
+	ResolvedBy     *string            `json:"resolved_by"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login of a user. It keeps the latest refresh token of its token
+// family, so that every device the user is logged in on rotates its own token.
+type Session struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	UserID       string             `json:"user_id"`
+	Family       string             `json:"family"`
+	RefreshToken string             `json:"refresh_token"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
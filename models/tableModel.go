@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Table struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	MenuID    *string            `json:"menu_id" validate:"required"`
+	Number    int                `json:"number" validate:"gte=0"`
+	Name      *string            `json:"name" validate:"required,max=50"`
+	Area      *string            `json:"area" validate:"omitempty,max=50"`
+	Seats     int                `json:"seats" validate:"gte=0,lte=100"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
@@ -7,16 +7,23 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"email,required"`
-	Phone         *string            `json:"phone" validate:"required"`
-	Token         *string            `json:"token"`
-	User_type     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
-	Refresh_token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID             primitive.ObjectID `bson:"_id"`
+	First_name     *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name      *string            `json:"last_name" validate:"required,min=2,max=100"`
+	Password       *string            `json:"Password" validate:"required,min=6"`
+	Email          *string            `json:"email" validate:"email,required"`
+	Phone          *string            `json:"phone" validate:"required"`
+	Email_verified bool               `json:"email_verified"`
+	Phone_verified bool               `json:"phone_verified"`
+	Totp_enabled   bool               `json:"totp_enabled"`
+	Totp_secret    *string            `json:"-"`
+	Totp_pending   *string            `json:"-"`
+	Totp_last_step int64              `json:"-"`
+	Recovery_codes []string           `json:"-"`
+	Token          *string            `json:"token"`
+	User_type      *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
+	Refresh_token  *string            `json:"refresh_token"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	User_id        string             `json:"user_id"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Verification struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	UserID    string             `json:"user_id"`
+	Channel   string             `json:"channel"`
+	Target    string             `json:"target"`
+	CodeHash  string             `json:"-"`
+	Attempts  int                `json:"attempts"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
@@ -3,9 +3,27 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	controller "github.com/sencerarslan/go-app/controllers"
+	"github.com/sencerarslan/go-app/middleware"
 )
 
 func AuthRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/register", controller.Signup())
-	incomingRoutes.POST("/login", controller.Login())
+	auth := incomingRoutes.Group("", authRateLimit())
+	auth.POST("/register", controller.Signup())
+	auth.POST("/login", controller.Login())
+	auth.POST("/login/2fa", controller.LoginTwoFactor())
+	auth.POST("/password/forgot", controller.ForgotPassword())
+	auth.POST("/password/reset", controller.ResetPassword())
+	auth.POST("/verify/email", middleware.Authenticate(), controller.VerifyEmail())
+	auth.POST("/verify/email/resend", middleware.Authenticate(), controller.ResendEmailVerification())
+	auth.POST("/verify/phone", middleware.Authenticate(), controller.VerifyPhone())
+	auth.POST("/verify/phone/resend", middleware.Authenticate(), controller.ResendPhoneVerification())
+	auth.POST("/2fa/setup", middleware.Authenticate(), controller.SetupTwoFactor())
+	auth.POST("/2fa/enable", middleware.Authenticate(), controller.EnableTwoFactor())
+	auth.POST("/2fa/disable", middleware.Authenticate(), controller.DisableTwoFactor())
+	auth.POST("/2fa/recovery", middleware.Authenticate(), controller.RegenerateRecoveryCodes())
+
+	session := incomingRoutes.Group("", sessionRateLimit())
+	session.POST("/token/refresh", controller.RefreshToken())
+	session.POST("/logout", middleware.Authenticate(), controller.Logout())
+	session.POST("/logout/all", middleware.Authenticate(), controller.LogoutAll())
 }
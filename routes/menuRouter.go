@@ -7,21 +7,42 @@ import (
 )
 
 func AuthMenuRoutes(incomingRoutes *gin.Engine) {
+	public := incomingRoutes.Group("", publicRateLimit())
+	public.POST("/menu/show", controller.ShowMenu())
+	public.POST("/menu/show/price", controller.ItemPrice())
+	public.GET("/menu/:id/qr", controller.MenuQRCode())
+	public.GET("/menu/:id/events", controller.PublicMenuEvents())
 
-	incomingRoutes.POST("/menu/show", controller.ShowMenu())
-
-	menu := incomingRoutes.Group("/menu")
+	menu := incomingRoutes.Group("/menu", apiRateLimit())
 	menu.POST("", middleware.Authenticate(), controller.GetMenu())
 	menu.POST("/add", middleware.Authenticate(), controller.AddUpdateMenu())
 	menu.POST("/delete", middleware.Authenticate(), controller.DeleteMenu())
+	menu.POST("/translation", middleware.Authenticate(), controller.AddUpdateTranslation())
+	menu.POST("/translation/delete", middleware.Authenticate(), controller.DeleteTranslation())
+	menu.POST("/trash", middleware.Authenticate(), controller.GetTrash())
+	menu.POST("/trash/restore", middleware.Authenticate(), controller.RestoreTrash())
+	menu.POST("/publish", middleware.Authenticate(), controller.PublishMenu())
+	menu.POST("/versions", middleware.Authenticate(), controller.GetMenuVersions())
+	menu.POST("/versions/diff", middleware.Authenticate(), controller.DiffMenuVersions())
+	menu.POST("/versions/rollback", middleware.Authenticate(), controller.RollbackMenu())
+	menu.POST("/schedule", middleware.Authenticate(), controller.GetScheduledPublishes())
+	menu.POST("/schedule/add", middleware.Authenticate(), controller.SchedulePublish())
+	menu.POST("/schedule/reschedule", middleware.Authenticate(), controller.ReschedulePublish())
+	menu.POST("/schedule/cancel", middleware.Authenticate(), controller.CancelScheduledPublish())
+	menu.GET("/:id/qr/sheet", middleware.Authenticate(), controller.MenuQRSheet())
+	menu.POST("/events/token", middleware.Authenticate(), controller.MenuEventsToken())
+	menu.GET("/events", middleware.AuthenticateStream(), controller.MenuEvents())
 
-	menuGroup := incomingRoutes.Group("/menu/group")
+	menuGroup := incomingRoutes.Group("/menu/group", apiRateLimit())
 	menuGroup.POST("", middleware.Authenticate(), controller.GetGroup())
 	menuGroup.POST("/add", middleware.Authenticate(), controller.AddUpdateGroup())
 	menuGroup.POST("/delete", middleware.Authenticate(), controller.DeleteGroup())
+	menuGroup.POST("/reorder", middleware.Authenticate(), controller.ReorderGroups())
 
-	menuGroupItem := incomingRoutes.Group("/menu/group/item")
+	menuGroupItem := incomingRoutes.Group("/menu/group/item", apiRateLimit())
 	menuGroupItem.POST("", middleware.Authenticate(), controller.GetItem())
 	menuGroupItem.POST("/add", middleware.Authenticate(), controller.AddUpdateItem())
 	menuGroupItem.POST("/delete", middleware.Authenticate(), controller.DeleteItem())
+	menuGroupItem.POST("/reorder", middleware.Authenticate(), controller.ReorderItems())
+	menuGroupItem.POST("/stock", middleware.Authenticate(), controller.UpdateItemStock())
 }
@@ -0,0 +1,444 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sencerarslan/go-app/database"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if helper.SECRET_KEY == "" {
+		helper.SECRET_KEY = "test"
+	}
+	os.Exit(m.Run())
+}
+
+// requireDatabase skips the test unless MONGODB_DATABASE names a scratch database
+// on a reachable MongoDB. The tests write to that database.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv("MONGODB_DATABASE") == "" {
+		t.Skip("set MONGODB_DATABASE to a scratch database to run the database tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := database.Client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB is not reachable: %v", err)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// menuFixture names a menu owned by owner with one group, one item, a published
+// version, a pending scheduled publish and a table.
+type menuFixture struct {
+	Menu     string
+	Group    string
+	Item     string
+	Schedule string
+	Table    string
+}
+
+// missingFixture names documents that do not exist.
+func missingFixture() menuFixture {
+	return menuFixture{
+		Menu:     primitive.NewObjectID().Hex(),
+		Group:    primitive.NewObjectID().Hex(),
+		Item:     primitive.NewObjectID().Hex(),
+		Schedule: primitive.NewObjectID().Hex(),
+		Table:    primitive.NewObjectID().Hex(),
+	}
+}
+
+// seedUser stores a verified user and returns an access token for it.
+func seedUser(t *testing.T, userType string) (string, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	email := id.Hex() + "@example.com"
+	user := models.User{
+		ID:             id,
+		First_name:     stringPtr("Test"),
+		Last_name:      stringPtr("User"),
+		Email:          &email,
+		Phone:          stringPtr("+900000000000"),
+		Email_verified: true,
+		User_type:      &userType,
+		Created_at:     time.Now(),
+		Updated_at:     time.Now(),
+		User_id:        id.Hex(),
+	}
+	users := database.OpenCollection(database.Client, "user")
+	if _, err := users.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		users.DeleteOne(context.Background(), bson.M{"_id": id})
+	})
+
+	token, _, err := helper.GenerateAllTokens(email, "Test", "User", userType, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id.Hex(), token
+}
+
+func seedMenu(t *testing.T, ownerID string) menuFixture {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	menuID := primitive.NewObjectID()
+	groupID := primitive.NewObjectID()
+	itemID := primitive.NewObjectID()
+	price, _ := models.ParseMoney("10")
+
+	menu := models.Menu{
+		ID:           menuID,
+		UserID:       &ownerID,
+		Name:         stringPtr("Menu"),
+		Logo:         stringPtr("logo.png"),
+		Banner:       stringPtr("banner.png"),
+		Currency:     helper.DefaultCurrency,
+		Timezone:     helper.DefaultTimezone,
+		Translations: map[string]models.Translation{},
+		MenuGroup:    []models.MenuGroup{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	group := models.MenuGroup{
+		ID:           groupID,
+		MenuID:       stringPtr(menuID.Hex()),
+		Name:         stringPtr("Group"),
+		Translations: map[string]models.Translation{},
+		MenuItem:     []models.MenuItem{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	item := models.MenuItem{
+		ID:           itemID,
+		GroupID:      stringPtr(groupID.Hex()),
+		Name:         stringPtr("Item"),
+		Price:        price,
+		OptionGroup:  []models.OptionGroup{},
+		Description:  stringPtr("Description"),
+		ImageURL:     stringPtr("item.png"),
+		Translations: map[string]models.Translation{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	table := models.Table{
+		ID:        primitive.NewObjectID(),
+		MenuID:    stringPtr(menuID.Hex()),
+		Number:    1,
+		Name:      stringPtr("Table 1"),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		database.OpenCollection(database.Client, "menu").DeleteOne(ctx, bson.M{"_id": menuID})
+		database.OpenCollection(database.Client, "menu-group").DeleteMany(ctx, bson.M{"menuid": menuID.Hex()})
+		database.OpenCollection(database.Client, "menu-item").DeleteMany(ctx, bson.M{"groupid": groupID.Hex()})
+		database.OpenCollection(database.Client, "menu-version").DeleteMany(ctx, bson.M{"menuid": menuID.Hex()})
+		database.OpenCollection(database.Client, "scheduled-publish").DeleteMany(ctx, bson.M{"menuid": menuID.Hex()})
+		database.OpenCollection(database.Client, "table").DeleteMany(ctx, bson.M{"menuid": menuID.Hex()})
+	})
+
+	inserts := []struct {
+		collection string
+		document   interface{}
+	}{
+		{"menu", menu},
+		{"menu-group", group},
+		{"menu-item", item},
+		{"table", table},
+	}
+	for _, insert := range inserts {
+		if _, err := database.OpenCollection(database.Client, insert.collection).InsertOne(ctx, insert.document); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := helper.PublishMenu(ctx, menuID, ownerID, nil); err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err := helper.SchedulePublish(ctx, menuID, ownerID, "2099-01-01 12:00", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return menuFixture{Menu: menuID.Hex(), Group: groupID.Hex(), Item: itemID.Hex(), Schedule: scheduled.ID.Hex(), Table: table.ID.Hex()}
+}
+
+func trash(t *testing.T, kind string, hex string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, _ := primitive.ObjectIDFromHex(hex)
+	var err error
+	switch kind {
+	case "menu":
+		err = helper.TrashMenu(ctx, id)
+	case "group":
+		err = helper.TrashGroup(ctx, id)
+	case "item":
+		err = helper.TrashItem(ctx, id)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type menuRouteCase struct {
+	name   string
+	method string
+	path   func(f menuFixture) string
+	body   func(f menuFixture) gin.H
+	setup  func(t *testing.T, f menuFixture)
+}
+
+func post(path string, body func(f menuFixture) gin.H) menuRouteCase {
+	return menuRouteCase{
+		method: http.MethodPost,
+		path:   func(menuFixture) string { return path },
+		body:   body,
+	}
+}
+
+func named(name string, c menuRouteCase) menuRouteCase {
+	c.name = name
+	return c
+}
+
+func withSetup(c menuRouteCase, setup func(t *testing.T, f menuFixture)) menuRouteCase {
+	c.setup = setup
+	return c
+}
+
+// menuRouteCases covers every menu, group and item route that addresses a
+// resource by ID.
+var menuRouteCases = []menuRouteCase{
+	named("update menu", post("/menu/add", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Menu, "name": "Renamed", "logo": "logo.png", "banner": "banner.png"}
+	})),
+	named("delete menu", post("/menu/delete", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Menu}
+	})),
+	named("translate menu", post("/menu/translation", func(f menuFixture) gin.H {
+		return gin.H{"type": "menu", "id": f.Menu, "locale": "en", "name": "Menu"}
+	})),
+	named("delete menu translation", post("/menu/translation/delete", func(f menuFixture) gin.H {
+		return gin.H{"type": "menu", "id": f.Menu, "locale": "en"}
+	})),
+	named("restore menu", withSetup(post("/menu/trash/restore", func(f menuFixture) gin.H {
+		return gin.H{"type": "menu", "id": f.Menu}
+	}), func(t *testing.T, f menuFixture) { trash(t, "menu", f.Menu) })),
+	named("publish menu", post("/menu/publish", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu}
+	})),
+	named("list versions", post("/menu/versions", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu}
+	})),
+	named("diff versions", post("/menu/versions/diff", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu, "from": 1}
+	})),
+	named("roll back menu", post("/menu/versions/rollback", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu, "version": 1}
+	})),
+	named("list scheduled publishes", post("/menu/schedule", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu}
+	})),
+	named("schedule publish", post("/menu/schedule/add", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu, "publish_at": "2099-01-01 12:00"}
+	})),
+	named("reschedule publish", post("/menu/schedule/reschedule", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Schedule, "publish_at": "2099-01-02 12:00"}
+	})),
+	named("cancel scheduled publish", post("/menu/schedule/cancel", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Schedule}
+	})),
+	{
+		name:   "menu QR sheet",
+		method: http.MethodGet,
+		path:   func(f menuFixture) string { return "/menu/" + f.Menu + "/qr/sheet" },
+	},
+
+	named("list groups", post("/menu/group", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Menu}
+	})),
+	named("add group", post("/menu/group/add", func(f menuFixture) gin.H {
+		return gin.H{"menu_id": f.Menu, "name": "Group"}
+	})),
+	named("update group", post("/menu/group/add", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Group, "menu_id": f.Menu, "name": "Renamed"}
+	})),
+	named("delete group", post("/menu/group/delete", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Group}
+	})),
+	named("reorder groups", post("/menu/group/reorder", func(f menuFixture) gin.H {
+		return gin.H{"parent_id": f.Menu, "ids": []string{f.Group}}
+	})),
+	named("translate group", post("/menu/translation", func(f menuFixture) gin.H {
+		return gin.H{"type": "group", "id": f.Group, "locale": "en", "name": "Group"}
+	})),
+	named("restore group", withSetup(post("/menu/trash/restore", func(f menuFixture) gin.H {
+		return gin.H{"type": "group", "id": f.Group}
+	}), func(t *testing.T, f menuFixture) { trash(t, "group", f.Group) })),
+
+	named("list items", post("/menu/group/item", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Group}
+	})),
+	named("add item", post("/menu/group/item/add", func(f menuFixture) gin.H {
+		return gin.H{"group_id": f.Group, "name": "Item", "description": "Description", "image_url": "item.png", "price": "12.50"}
+	})),
+	named("update item", post("/menu/group/item/add", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Item, "name": "Renamed", "description": "Description", "image_url": "item.png", "price": "12.50"}
+	})),
+	named("delete item", post("/menu/group/item/delete", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Item}
+	})),
+	named("reorder items", post("/menu/group/item/reorder", func(f menuFixture) gin.H {
+		return gin.H{"parent_id": f.Group, "ids": []string{f.Item}}
+	})),
+	named("update item stock", post("/menu/group/item/stock", func(f menuFixture) gin.H {
+		return gin.H{"id": f.Item, "stock": 5}
+	})),
+	named("translate item", post("/menu/translation", func(f menuFixture) gin.H {
+		return gin.H{"type": "item", "id": f.Item, "locale": "en", "name": "Item"}
+	})),
+	named("restore item", withSetup(post("/menu/trash/restore", func(f menuFixture) gin.H {
+		return gin.H{"type": "item", "id": f.Item}
+	}), func(t *testing.T, f menuFixture) { trash(t, "item", f.Item) })),
+}
+
+func serveMenuRoute(t *testing.T, router *gin.Engine, route menuRouteCase, f menuFixture, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	if route.body != nil {
+		if err := json.NewEncoder(&body).Encode(route.body(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(route.method, route.path(f), &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Token", token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMenuRoutesCheckOwnership(t *testing.T) {
+	requireDatabase(t)
+
+	router := gin.New()
+	AuthMenuRoutes(router)
+
+	ownerID, _ := seedUser(t, "USER")
+	_, otherToken := seedUser(t, "USER")
+	_, adminToken := seedUser(t, "ADMIN")
+
+	for _, route := range menuRouteCases {
+		route := route
+		t.Run(route.name, func(t *testing.T) {
+			t.Run("other user is forbidden", func(t *testing.T) {
+				f := seedMenu(t, ownerID)
+				if route.setup != nil {
+					route.setup(t, f)
+				}
+				w := serveMenuRoute(t, router, route, f, otherToken)
+				if w.Code != http.StatusForbidden {
+					t.Errorf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+				}
+			})
+
+			t.Run("admin may act on any menu", func(t *testing.T) {
+				f := seedMenu(t, ownerID)
+				if route.setup != nil {
+					route.setup(t, f)
+				}
+				w := serveMenuRoute(t, router, route, f, adminToken)
+				if w.Code != http.StatusOK {
+					t.Errorf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+				}
+			})
+
+			t.Run("missing ID is not found", func(t *testing.T) {
+				w := serveMenuRoute(t, router, route, missingFixture(), adminToken)
+				if w.Code != http.StatusNotFound {
+					t.Errorf("status = %d, want %d: %s", w.Code, http.StatusNotFound, w.Body)
+				}
+			})
+		})
+	}
+}
+
+func TestMenuRoutesRefuseTrashedParents(t *testing.T) {
+	requireDatabase(t)
+
+	router := gin.New()
+	AuthMenuRoutes(router)
+
+	ownerID, ownerToken := seedUser(t, "USER")
+	trashMenu := func(t *testing.T, f menuFixture) { trash(t, "menu", f.Menu) }
+	trashGroup := func(t *testing.T, f menuFixture) { trash(t, "group", f.Group) }
+
+	routes := []menuRouteCase{
+		named("add group to trashed menu", withSetup(post("/menu/group/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "name": "Group"}
+		}), trashMenu)),
+		named("reorder groups of trashed menu", withSetup(post("/menu/group/reorder", func(f menuFixture) gin.H {
+			return gin.H{"parent_id": f.Menu, "ids": []string{f.Group}}
+		}), trashMenu)),
+		named("restore group of trashed menu", withSetup(post("/menu/trash/restore", func(f menuFixture) gin.H {
+			return gin.H{"type": "group", "id": f.Group}
+		}), trashMenu)),
+		named("add item to trashed group", withSetup(post("/menu/group/item/add", func(f menuFixture) gin.H {
+			return gin.H{"group_id": f.Group, "name": "Item", "description": "Description", "image_url": "item.png", "price": "12.50"}
+		}), trashGroup)),
+		named("update item of trashed group", withSetup(post("/menu/group/item/add", func(f menuFixture) gin.H {
+			return gin.H{"id": f.Item, "name": "Renamed", "description": "Description", "image_url": "item.png", "price": "12.50"}
+		}), trashGroup)),
+		named("reorder items of trashed group", withSetup(post("/menu/group/item/reorder", func(f menuFixture) gin.H {
+			return gin.H{"parent_id": f.Group, "ids": []string{f.Item}}
+		}), trashGroup)),
+		named("restore item of trashed group", withSetup(post("/menu/trash/restore", func(f menuFixture) gin.H {
+			return gin.H{"type": "item", "id": f.Item}
+		}), trashGroup)),
+	}
+
+	for _, route := range routes {
+		route := route
+		t.Run(route.name, func(t *testing.T) {
+			f := seedMenu(t, ownerID)
+			route.setup(t, f)
+			w := serveMenuRoute(t, router, route, f, ownerToken)
+			if w.Code != http.StatusConflict {
+				t.Errorf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+			}
+		})
+	}
+}
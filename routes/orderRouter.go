@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/sencerarslan/go-app/controllers"
+	"github.com/sencerarslan/go-app/middleware"
+)
+
+func OrderRoutes(incomingRoutes *gin.Engine) {
+	order := incomingRoutes.Group("/order")
+	order.POST("", publicRateLimit(), controller.SubmitOrder())
+	order.POST("/list", apiRateLimit(), middleware.Authenticate(), controller.ListOrders())
+	order.POST("/status", apiRateLimit(), middleware.Authenticate(), controller.UpdateOrderStatus())
+}
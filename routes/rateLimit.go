@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	helper "github.com/sencerarslan/go-app/helpers"
+	"github.com/sencerarslan/go-app/middleware"
+)
+
+// The policies are built when the routes are registered, after the environment
+// is loaded. Each one can be changed with RATE_LIMIT_<NAME>, see
+// helper.RateLimitPolicyFor.
+
+// authRateLimit guards sign up, login and the other account endpoints against
+// password guessing and mail flooding.
+func authRateLimit() gin.HandlerFunc {
+	return middleware.RateLimit(helper.RateLimitPolicyFor("auth", 10, time.Minute), middleware.ByIP)
+}
+
+// sessionRateLimit guards token refresh and logout. These only act on a token the
+// client already holds, so they are counted per user, or per address when the
+// access token has expired, apart from the password guessing budget of auth.
+func sessionRateLimit() gin.HandlerFunc {
+	return middleware.RateLimit(helper.RateLimitPolicyFor("session", 60, time.Minute), middleware.ByUser)
+}
+
+// publicRateLimit guards the endpoints that guests use without signing in. Clients
+// with a known API key get a bucket of their own instead of sharing their address.
+func publicRateLimit() gin.HandlerFunc {
+	return middleware.RateLimit(helper.RateLimitPolicyFor("public", 120, time.Minute), middleware.ByAPIKey, middleware.ByIP)
+}
+
+// apiRateLimit guards the endpoints of signed in users, per user.
+func apiRateLimit() gin.HandlerFunc {
+	return middleware.RateLimit(helper.RateLimitPolicyFor("api", 300, time.Minute), middleware.ByUser)
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/sencerarslan/go-app/controllers"
+	"github.com/sencerarslan/go-app/middleware"
+)
+
+func ServiceRoutes(incomingRoutes *gin.Engine) {
+	service := incomingRoutes.Group("/service")
+	service.POST("/call", publicRateLimit(), controller.CallService())
+	service.POST("", apiRateLimit(), middleware.Authenticate(), controller.GetServiceRequests())
+	service.POST("/acknowledge", apiRateLimit(), middleware.Authenticate(), controller.AcknowledgeServiceRequest())
+	service.POST("/resolve", apiRateLimit(), middleware.Authenticate(), controller.ResolveServiceRequest())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/sencerarslan/go-app/controllers"
+	"github.com/sencerarslan/go-app/middleware"
+)
+
+func TableRoutes(incomingRoutes *gin.Engine) {
+	table := incomingRoutes.Group("/table", apiRateLimit())
+	table.POST("", middleware.Authenticate(), controller.GetTables())
+	table.POST("/add", middleware.Authenticate(), controller.AddUpdateTable())
+	table.POST("/delete", middleware.Authenticate(), controller.DeleteTable())
+	table.POST("/bulk", middleware.Authenticate(), controller.BulkCreateTables())
+	table.GET("/:id/qr", middleware.Authenticate(), controller.TableQRCode())
+}
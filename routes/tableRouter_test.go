@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTableRoutesRefuseTrashedMenus(t *testing.T) {
+	requireDatabase(t)
+
+	router := gin.New()
+	TableRoutes(router)
+
+	ownerID, ownerToken := seedUser(t, "USER")
+	trashMenu := func(t *testing.T, f menuFixture) { trash(t, "menu", f.Menu) }
+
+	routes := []menuRouteCase{
+		named("add table to trashed menu", withSetup(post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 2, "name": "Table 2"}
+		}), trashMenu)),
+		named("update table of trashed menu", withSetup(post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"id": f.Table, "menu_id": f.Menu, "number": 1, "name": "Renamed"}
+		}), trashMenu)),
+		named("delete table of trashed menu", withSetup(post("/table/delete", func(f menuFixture) gin.H {
+			return gin.H{"id": f.Table}
+		}), trashMenu)),
+		named("bulk create tables on trashed menu", withSetup(post("/table/bulk", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "from": 2, "to": 3}
+		}), trashMenu)),
+	}
+
+	for _, route := range routes {
+		route := route
+		t.Run(route.name, func(t *testing.T) {
+			f := seedMenu(t, ownerID)
+			route.setup(t, f)
+			w := serveMenuRoute(t, router, route, f, ownerToken)
+			if w.Code != http.StatusConflict {
+				t.Errorf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+			}
+		})
+	}
+}
+
+func TestTableNumbersAreUniquePerMenu(t *testing.T) {
+	requireDatabase(t)
+
+	router := gin.New()
+	TableRoutes(router)
+
+	ownerID, ownerToken := seedUser(t, "USER")
+	f := seedMenu(t, ownerID)
+	other := seedMenu(t, ownerID)
+
+	cases := []struct {
+		name  string
+		route menuRouteCase
+		f     menuFixture
+		want  int
+	}{
+		{"add a taken number", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 1, "name": "Another 1"}
+		}), f, http.StatusConflict},
+		{"add a free number", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 2, "name": "Table 2"}
+		}), f, http.StatusOK},
+		{"move a table onto a taken number", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"id": f.Table, "menu_id": f.Menu, "number": 2, "name": "Table 1"}
+		}), f, http.StatusConflict},
+		{"add a number taken on another menu", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 2, "name": "Table 2"}
+		}), other, http.StatusOK},
+		{"add unnumbered tables", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 0, "name": "Bar"}
+		}), f, http.StatusOK},
+		{"add another unnumbered table", post("/table/add", func(f menuFixture) gin.H {
+			return gin.H{"menu_id": f.Menu, "number": 0, "name": "Terrace"}
+		}), f, http.StatusOK},
+	}
+	for _, tc := range cases {
+		w := serveMenuRoute(t, router, tc.route, tc.f, ownerToken)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, w.Code, tc.want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/sencerarslan/go-app/controllers"
+	"github.com/sencerarslan/go-app/middleware"
+)
+
+func UploadRoutes(incomingRoutes *gin.Engine) {
+	upload := incomingRoutes.Group("/upload", apiRateLimit())
+	upload.POST("/image", middleware.Authenticate(), controller.UploadImage())
+}
@@ -7,6 +7,9 @@ import (
 )
 
 func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", middleware.Authenticate(), controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", middleware.Authenticate(), controller.GetUser())
+	users := incomingRoutes.Group("/users", apiRateLimit())
+	users.GET("", middleware.Authenticate(), controller.GetUsers())
+	users.GET("/:user_id", middleware.Authenticate(), controller.GetUser())
+	users.POST("/unlock", middleware.Authenticate(), controller.UnlockAccount())
+	users.POST("/audit", middleware.Authenticate(), controller.GetAuditLogs())
 }